@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oleiade/reflections"
+)
+
+// Client info is what a folder name alone cannot tell, kept in the metadata directory:
+//
+//	<Tgkdir>/.fastSwapper/info/<client>.json   {"customer": "...", "version": "...", "notes": "..."}
+//
+// It is written with the info command and searched by the TUI filter.
+
+const (
+	INFO_COMMAND = "info"
+)
+
+type clientInfo struct {
+	Customer string `json:"customer"`
+	Version  string `json:"version"`
+	Notes    string `json:"notes"`
+}
+
+// maps the field names used on the command line to the fields of clientInfo
+var clientInfoFields = map[string]string{
+	"customer": "Customer",
+	"version":  "Version",
+	"notes":    "Notes",
+}
+
+func infoPath(tgkDir string, client string) string {
+	return filepath.Join(metaDirPath(tgkDir), "info", client+".json")
+}
+
+// readClientInfo returns the info of client, empty if none was written yet.
+func readClientInfo(tgkDir string, client string) clientInfo {
+	var info clientInfo
+	b, err := os.ReadFile(infoPath(tgkDir, client))
+	if err != nil {
+		return info
+	}
+	json.Unmarshal(b, &info)
+	return info
+}
+
+func writeClientInfo(tgkDir string, client string, info clientInfo) error {
+	path := infoPath(tgkDir, client)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(info, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// setClientInfo changes one field of the info of client, field is one of the keys of clientInfoFields.
+func setClientInfo(set Settings, client string, field string, value string) (clientInfo, error) {
+	tgkDir := set.Defaults.Tgkdir
+	info := readClientInfo(tgkDir, client)
+	name, ok := clientInfoFields[field]
+	if !ok {
+		return info, newCLIError(ERR_INVALID_ARGUMENT, "Field must be one of customer, version or notes.")
+	}
+	if client != set.ActiveSettings.OldDirectory && !clientExists(tgkDir, client) {
+		return info, newCLIError(ERR_NOT_FOUND, "There is no client called "+client+".")
+	}
+	err := reflections.SetField(&info, name, value)
+	if err != nil {
+		return info, err
+	}
+	return info, writeClientInfo(tgkDir, client, info)
+}
+
+func (info clientInfo) String() string {
+	return fmt.Sprintf("customer: %s\nversion:  %s\nnotes:    %s\n", info.Customer, info.Version, info.Notes)
+}
+
+// searchFields returns the fields the TUI filter looks at besides the name, by label.
+func (info clientInfo) searchFields() [][2]string {
+	fields := make([][2]string, 0, 3)
+	for _, f := range [][2]string{{"customer", info.Customer}, {"version", info.Version}, {"notes", info.Notes}} {
+		if strings.TrimSpace(f[1]) != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// loadClientInfos reads the info of every client in clients.
+func loadClientInfos(tgkDir string, clients []string) map[string]clientInfo {
+	infos := make(map[string]clientInfo, len(clients))
+	for _, c := range clients {
+		infos[c] = readClientInfo(tgkDir, c)
+	}
+	return infos
+}
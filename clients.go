@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// Managing the clients in Tgkdir: rename, duplicate and delete. Deleted clients are not removed but moved into the
+// trash inside the metadata directory, one entry per deletion:
+//
+//	<Tgkdir>/.fastSwapper/trash/<id>/entry.json   which client was deleted when
+//	<Tgkdir>/.fastSwapper/trash/<id>/folder       the client folder, if it had one
+//	<Tgkdir>/.fastSwapper/trash/<id>/<kind>.json  its manifest, checksums, template vars and info
+//
+// A client is more than its folder, everything the metadata directory keeps under its name moves along with it.
+
+const (
+	RENAME_COMMAND    = "rename"
+	DUPLICATE_COMMAND = "duplicate"
+	DELETE_COMMAND    = "delete"
+	TRASH_COMMAND     = "trash"
+	RESTORE_COMMAND   = "restore"
+	TRASH_DIR_NAME    = "trash"
+)
+
+type trashEntry struct {
+	ID      string    `json:"id"`
+	Client  string    `json:"client"`
+	Deleted time.Time `json:"deleted"`
+	User    string    `json:"user"`
+}
+
+func (e trashEntry) String() string {
+	return fmt.Sprintf("%s  %-20s deleted %s by %s", e.ID, e.Client, e.Deleted.Local().Format("2006-01-02 15:04:05"), e.User)
+}
+
+func trashDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), TRASH_DIR_NAME)
+}
+
+// clientMetaFiles returns the files of the metadata directory that belong to client, by kind.
+func clientMetaFiles(tgkDir string, client string) map[string]string {
+	return map[string]string{
+		"manifest":  manifestPath(tgkDir, client),
+		"checksums": checksumPath(tgkDir, client),
+		"vars":      varsPath(tgkDir, client),
+		"info":      infoPath(tgkDir, client),
+	}
+}
+
+// moveFile renames src to dst and creates the parent of dst if needed; a missing src is nothing to move.
+func moveFile(src string, dst string) error {
+	if !utils.Exists(src) {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// renameManifest fixes the client name recorded inside a manifest or checksum record at path.
+func renameManifest(path string, client string) error {
+	if !utils.Exists(path) {
+		return nil
+	}
+	m, err := readManifest(path)
+	if err != nil {
+		return err
+	}
+	m.Client = client
+	return writeManifest(path, m)
+}
+
+// checkClientName makes sure name can be the name of a client in Tgkdir at all. The addin folder and the metadata
+// directory live next to the clients, a client of their name would move them instead.
+func checkClientName(set Settings, name string) error {
+	err := validClientName(name)
+	if err != nil {
+		return err
+	}
+	// path separators are forbidden characters already
+	if name == "." || name == ".." {
+		return newCLIError(ERR_INVALID_NAME, fmt.Sprintf("%s is not a client name.", name))
+	}
+	// Windows does not tell names apart by case
+	for _, reserved := range []string{set.Defaults.Tgkfolder, META_DIR_NAME} {
+		if strings.EqualFold(name, reserved) {
+			return newCLIError(ERR_INVALID_NAME, fmt.Sprintf("%s is reserved for the swapper and cannot be a client.", reserved))
+		}
+	}
+	return nil
+}
+
+// checkNewClientName makes sure name can be used for a new client.
+func checkNewClientName(set Settings, name string) error {
+	err := checkClientName(set, name)
+	if err != nil {
+		return err
+	}
+	if clientExists(set.Defaults.Tgkdir, name) {
+		return newCLIError(ERR_NAME_COLLISION, fmt.Sprintf("There already is a client called %s.", name))
+	}
+	return nil
+}
+
+// renameClient renames client to newName. Renaming the active client only changes the name it will be saved under.
+func renameClient(set Settings, settingsFileName string, client string, newName string) error {
+	tgkDir := set.Defaults.Tgkdir
+	err := checkClientName(set, client)
+	if err != nil {
+		return err
+	}
+	err = checkNewClientName(set, newName)
+	if err != nil {
+		return err
+	}
+	active := client == set.ActiveSettings.OldDirectory
+	if !active && !clientExists(tgkDir, client) {
+		return newCLIError(ERR_NOT_FOUND, "There is no client called "+client+".")
+	}
+	if !active && utils.Exists(filepath.Join(tgkDir, client)) {
+		err = os.Rename(filepath.Join(tgkDir, client), filepath.Join(tgkDir, newName))
+		if err != nil {
+			return err
+		}
+	}
+	newFiles := clientMetaFiles(tgkDir, newName)
+	for kind, path := range clientMetaFiles(tgkDir, client) {
+		err = moveFile(path, newFiles[kind])
+		if err != nil {
+			return err
+		}
+	}
+	err = renameManifest(newFiles["manifest"], newName)
+	if err != nil {
+		return err
+	}
+	err = renameManifest(newFiles["checksums"], newName)
+	if err != nil {
+		return err
+	}
+	if active {
+		setActiveSettings(settingsFileName, "OldDirectory", newName)
+		if err := writeMarker(filepath.Join(tgkDir, set.Defaults.Tgkfolder), newName); err != nil {
+			slog.Warn("could not write client marker", "error", err)
+		}
+	}
+	if set.ActiveSettings.PreviousDirectory == client {
+		setActiveSettings(settingsFileName, "PreviousDirectory", newName)
+	}
+	slog.Info("client renamed", "from", client, "to", newName, "active", active)
+	return nil
+}
+
+// duplicateClient copies client to newName. In store mode a client without folder is duplicated by its manifest,
+// so the copy shares all blobs.
+func duplicateClient(set Settings, client string, newName string) error {
+	tgkDir := set.Defaults.Tgkdir
+	err := checkClientName(set, client)
+	if err != nil {
+		return err
+	}
+	err = checkNewClientName(set, newName)
+	if err != nil {
+		return err
+	}
+	folderPath := clientFolderPath(set, client)
+	newFiles := clientMetaFiles(tgkDir, newName)
+	oldFiles := clientMetaFiles(tgkDir, client)
+	switch {
+	case utils.Exists(folderPath):
+		err = utils.CopyDir(folderPath, filepath.Join(tgkDir, newName))
+		if err != nil {
+			return err
+		}
+		// the copy of the addin folder must not claim to be the active client
+		os.Remove(filepath.Join(tgkDir, newName, MARKER_FILE_NAME))
+		// nor carry its rendered config files, the duplicate gets its own vars below
+		if client == set.ActiveSettings.OldDirectory {
+			_, err = writeOriginals(tgkDir, filepath.Join(tgkDir, newName))
+			if err != nil {
+				os.RemoveAll(filepath.Join(tgkDir, newName))
+				return err
+			}
+		}
+	case utils.Exists(oldFiles["manifest"]):
+		err = utils.CopyFile(oldFiles["manifest"], newFiles["manifest"], 0644)
+		if err != nil {
+			return err
+		}
+		err = renameManifest(newFiles["manifest"], newName)
+		if err != nil {
+			return err
+		}
+	default:
+		return newCLIError(ERR_NOT_FOUND, "There is no client called "+client+".")
+	}
+	for _, kind := range []string{"checksums", "vars", "info"} {
+		if !utils.Exists(oldFiles[kind]) {
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(newFiles[kind]), 0755)
+		if err != nil {
+			return err
+		}
+		err = utils.CopyFile(oldFiles[kind], newFiles[kind], 0644)
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("client duplicated", "from", client, "to", newName)
+	return renameManifest(newFiles["checksums"], newName)
+}
+
+// deleteClient moves client into the trash.
+func deleteClient(set Settings, settingsFileName string, client string) (trashEntry, error) {
+	tgkDir := set.Defaults.Tgkdir
+	entry := trashEntry{Client: client, Deleted: time.Now(), User: currentUser()}
+	if err := checkClientName(set, client); err != nil {
+		return entry, err
+	}
+	if client == set.ActiveSettings.OldDirectory {
+		return entry, newCLIError(ERR_INVALID_ARGUMENT, "The active client cannot be deleted, swap in another one first.")
+	}
+	if !clientExists(tgkDir, client) {
+		return entry, newCLIError(ERR_NOT_FOUND, "There is no client called "+client+".")
+	}
+	entry.ID = entry.Deleted.Format("20060102-150405")
+	for i := 1; utils.Exists(filepath.Join(trashDirPath(tgkDir), entry.ID)); i++ {
+		entry.ID = fmt.Sprintf("%s-%d", entry.Deleted.Format("20060102-150405"), i)
+	}
+	entryDir := filepath.Join(trashDirPath(tgkDir), entry.ID)
+	err := os.MkdirAll(entryDir, 0755)
+	if err != nil {
+		return entry, err
+	}
+	b, err := json.MarshalIndent(entry, "", "    ")
+	if err != nil {
+		return entry, err
+	}
+	err = os.WriteFile(filepath.Join(entryDir, "entry.json"), b, 0644)
+	if err != nil {
+		return entry, err
+	}
+	err = moveFile(filepath.Join(tgkDir, client), filepath.Join(entryDir, "folder"))
+	if err != nil {
+		return entry, err
+	}
+	for kind, path := range clientMetaFiles(tgkDir, client) {
+		err = moveFile(path, filepath.Join(entryDir, kind+".json"))
+		if err != nil {
+			return entry, err
+		}
+	}
+	if set.ActiveSettings.PreviousDirectory == client {
+		setActiveSettings(settingsFileName, "PreviousDirectory", "")
+	}
+	slog.Info("client moved to the trash", "client", client, "id", entry.ID)
+	return entry, nil
+}
+
+// readTrash returns the entries of the trash, most recently deleted first.
+func readTrash(tgkDir string) []trashEntry {
+	entries := make([]trashEntry, 0)
+	dirs, err := os.ReadDir(trashDirPath(tgkDir))
+	if err != nil {
+		// no trash yet
+		return entries
+	}
+	for _, d := range dirs {
+		b, err := os.ReadFile(filepath.Join(trashDirPath(tgkDir), d.Name(), "entry.json"))
+		if err != nil {
+			continue
+		}
+		var e trashEntry
+		if json.Unmarshal(b, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Deleted.After(entries[j].Deleted) })
+	return entries
+}
+
+// trashedManifests returns the manifests of all clients in the trash, their blobs must survive a store gc.
+func trashedManifests(tgkDir string) []string {
+	return utils.Map(readTrash(tgkDir), func(e trashEntry) string {
+		return filepath.Join(trashDirPath(tgkDir), e.ID, "manifest.json")
+	})
+}
+
+// restoreClient moves a client back out of the trash. which is the id of an entry or the name of a client, in which
+// case the most recently deleted client of that name is restored.
+func restoreClient(set Settings, which string) (trashEntry, error) {
+	tgkDir := set.Defaults.Tgkdir
+	var entry trashEntry
+	found := false
+	for _, e := range readTrash(tgkDir) {
+		if e.ID == which || e.Client == which {
+			entry, found = e, true
+			break
+		}
+	}
+	if !found {
+		return entry, newCLIError(ERR_NOT_FOUND, "Nothing in the trash matches "+which+".")
+	}
+	// entries are plain files, one could have been written by hand
+	if err := checkClientName(set, entry.Client); err != nil {
+		return entry, err
+	}
+	if clientExists(tgkDir, entry.Client) || entry.Client == set.ActiveSettings.OldDirectory {
+		return entry, newCLIError(ERR_NAME_COLLISION, fmt.Sprintf("There already is a client called %s, rename it first.", entry.Client))
+	}
+	entryDir := filepath.Join(trashDirPath(tgkDir), entry.ID)
+	err := moveFile(filepath.Join(entryDir, "folder"), filepath.Join(tgkDir, entry.Client))
+	if err != nil {
+		return entry, err
+	}
+	for kind, path := range clientMetaFiles(tgkDir, entry.Client) {
+		err = moveFile(filepath.Join(entryDir, kind+".json"), path)
+		if err != nil {
+			return entry, err
+		}
+	}
+	slog.Info("client restored from the trash", "client", entry.Client, "id", entry.ID)
+	return entry, os.RemoveAll(entryDir)
+}
+
+// results of the client commands with JSON output
+
+type clientActionResult struct {
+	Client string `json:"client"`
+	// the new name for rename and duplicate, the trash entry for delete and restore
+	Name string `json:"name,omitempty"`
+}
+
+type trashResult struct {
+	Entries []trashEntry `json:"entries"`
+}
+
+func (r trashResult) String() string {
+	if len(r.Entries) == 0 {
+		return "The trash is empty.\n"
+	}
+	s := ""
+	for _, e := range r.Entries {
+		s += e.String() + "\n"
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fastSwapper/utils"
+)
+
+func Test_duplicateClient_active(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	addin := filepath.Join(tgkDir, "Addin")
+	writeFiles(t, addin, map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "server=original", "addin.dll": "dll"})
+	writeFiles(t, metaDirPath(tgkDir), map[string]string{
+		"templates/app.config":  "server={{.Server}}",
+		"templates/user.config": "user={{.User}}",
+		"vars/Kunde A.json":     `{"Server": "srv-a", "User": "a"}`,
+	})
+	rendered, err := renderTemplates(tgkDir, "Kunde A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyTemplates(tgkDir, addin, "Kunde A", rendered); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := duplicateClient(set, "Kunde A", "Kunde Z"); err != nil {
+		t.Fatal(err)
+	}
+	copied := filepath.Join(tgkDir, "Kunde Z")
+	if b, _ := os.ReadFile(filepath.Join(copied, "app.config")); string(b) != "server=original" {
+		t.Errorf("Duplicate got the rendered app.config: %q", b)
+	}
+	for _, name := range []string{"user.config", MARKER_FILE_NAME} {
+		if utils.Exists(filepath.Join(copied, name)) {
+			t.Errorf("Duplicate contains %s", name)
+		}
+	}
+	if !utils.Exists(varsPath(tgkDir, "Kunde Z")) {
+		t.Errorf("Duplicate did not get the vars of Kunde A")
+	}
+	// the active client keeps its rendered files and can still be restored
+	if b, _ := os.ReadFile(filepath.Join(addin, "app.config")); string(b) != "server=srv-a" {
+		t.Errorf("Duplicating changed the active app.config to %q", b)
+	}
+	if err := restoreTemplates(tgkDir, addin); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(addin, "app.config")); string(b) != "server=original" {
+		t.Errorf("Active client could not be restored after duplicating, app.config is %q", b)
+	}
+}
+
+func Test_clientCommands_reservedNames(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	writeFiles(t, tgkDir, map[string]string{"Addin/addin.dll": "dll", "Kunde B/addin.dll": "dll"})
+	rejected := []string{"Addin", "addin", META_DIR_NAME, ".", "..", "a/b", `a\b`}
+
+	wantInvalid := func(what string, name string, err error) {
+		t.Helper()
+		var cliErr *cliError
+		if !errors.As(err, &cliErr) || cliErr.Code != ERR_INVALID_NAME {
+			t.Errorf("%s with %q did not fail with %s: %v", what, name, ERR_INVALID_NAME, err)
+		}
+	}
+	for _, name := range rejected {
+		wantInvalid("Renaming", name, renameClient(set, filepath.Join(tgkDir, "settings.json"), name, "Kunde Z"))
+		wantInvalid("Renaming to", name, renameClient(set, filepath.Join(tgkDir, "settings.json"), "Kunde B", name))
+		wantInvalid("Duplicating", name, duplicateClient(set, name, "Kunde Z"))
+		wantInvalid("Duplicating to", name, duplicateClient(set, "Kunde B", name))
+		_, err := deleteClient(set, filepath.Join(tgkDir, "settings.json"), name)
+		wantInvalid("Deleting", name, err)
+	}
+	for i, name := range rejected {
+		id := fmt.Sprintf("entry%d", i)
+		b, err := json.Marshal(trashEntry{ID: id, Client: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFiles(t, filepath.Join(trashDirPath(tgkDir), id), map[string]string{"entry.json": string(b), "folder/addin.dll": "dll"})
+		_, err = restoreClient(set, id)
+		wantInvalid("Restoring", name, err)
+	}
+
+	// nothing was moved
+	for _, path := range []string{filepath.Join(tgkDir, "Addin", "addin.dll"), filepath.Join(tgkDir, "Kunde B", "addin.dll"), metaDirPath(tgkDir)} {
+		if !utils.Exists(path) {
+			t.Errorf("%s is gone", path)
+		}
+	}
+	if utils.Exists(filepath.Join(tgkDir, "Kunde Z")) {
+		t.Errorf("A client was created from a reserved name")
+	}
+}
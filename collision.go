@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// A swap renames the addin folder to OldDirectory. If a folder of that name already exists in Tgkdir the rename
+// fails, or on some filesystems moves the addin folder into it, so swaps check for that first and handle it according
+// to Default.CollisionStrategy. In store mode the same goes for a manifest of that name that belongs to another client.
+
+const (
+	SET_COLLISION_STRATEGY_FLAG = "-cs"
+	COLLISION_ABORT             = "abort"
+	COLLISION_SUFFIX            = "suffix"
+	COLLISION_ARCHIVE           = "archive"
+	// the TUI asks for another name, everywhere else this is the same as abort
+	COLLISION_PROMPT = "prompt"
+	ARCHIVE_DIR_NAME = "archive"
+)
+
+var collisionStrategies = []string{COLLISION_ABORT, COLLISION_SUFFIX, COLLISION_ARCHIVE, COLLISION_PROMPT}
+
+type collisionError struct {
+	// the name the outgoing client would get
+	Name string
+	Path string
+	// the first free name, offered as alternative
+	Suggestion string
+	// the name is the one of the client being swapped in, no strategy can resolve that
+	Incoming bool
+}
+
+func (e *collisionError) Error() string {
+	if e.Incoming {
+		return fmt.Sprintf("The active client would be saved as %s, which is the client being swapped in. The settings are probably out of date, check with fastSwapper status or save the active client under another name with fastSwapper -o %q.",
+			e.Name, e.Suggestion)
+	}
+	return fmt.Sprintf("The active client would be saved as %s, but %s already exists. Rename it, choose another name with fastSwapper -o %q, or set a collision strategy with fastSwapper -cs <%s|%s|%s>.",
+		e.Name, e.Path, e.Suggestion, COLLISION_SUFFIX, COLLISION_ARCHIVE, COLLISION_PROMPT)
+}
+
+func archiveDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), ARCHIVE_DIR_NAME)
+}
+
+// archiveMove is the move of a colliding folder or manifest into the archive. The zero value moves nothing.
+type archiveMove struct {
+	From string
+	To   string
+}
+
+func (a archiveMove) apply() error {
+	if a.From == "" {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(a.To), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(a.From, a.To)
+	if err != nil {
+		return err
+	}
+	slog.Info("existing client archived", "from", a.From, "to", a.To)
+	return nil
+}
+
+// collidingPath returns what already takes the name the active client of set is about to be saved under, empty if
+// nothing does. The store manifest the active client came from is its own and no collision.
+func collidingPath(set Settings) string {
+	tgkDir := set.Defaults.Tgkdir
+	name := set.ActiveSettings.OldDirectory
+	if path := filepath.Join(tgkDir, name); utils.Exists(path) {
+		return path
+	}
+	if set.Defaults.StorageMode != STORAGE_MODE_STORE {
+		return ""
+	}
+	marker := readMarker(filepath.Join(tgkDir, set.Defaults.Tgkfolder))
+	if path := manifestPath(tgkDir, name); marker != "" && marker != name && utils.Exists(path) {
+		return path
+	}
+	return ""
+}
+
+// resolveCollision makes sure the active client of set can be saved under its name and returns set with the name it
+// will actually be saved under. Nothing is moved yet: with the archive strategy the returned move has to be applied
+// by the swap itself, once it is certain to go ahead. Saving it under the name of newDirName, the client being swapped
+// in, is always refused: archiving or renaming would take away the very client the swap is about.
+func resolveCollision(set Settings, newDirName string) (Settings, archiveMove, error) {
+	tgkDir := set.Defaults.Tgkdir
+	name := set.ActiveSettings.OldDirectory
+	if name == newDirName {
+		slog.Warn("outgoing client has the name of the incoming one", "name", name)
+		return set, archiveMove{}, &collisionError{Name: name, Path: filepath.Join(tgkDir, name), Suggestion: freeClientName(tgkDir, name), Incoming: true}
+	}
+	path := collidingPath(set)
+	if path == "" {
+		return set, archiveMove{}, nil
+	}
+	strategy := set.Defaults.CollisionStrategy
+	slog.Warn("outgoing client collides with an existing folder", "path", path, "strategy", strategy)
+	switch strategy {
+	case COLLISION_SUFFIX:
+		set.ActiveSettings.OldDirectory = freeClientName(tgkDir, name)
+		slog.Info("outgoing client renamed", "from", name, "to", set.ActiveSettings.OldDirectory)
+		return set, archiveMove{}, nil
+	case COLLISION_ARCHIVE:
+		// a manifest keeps its extension
+		archived := filepath.Join(archiveDirPath(tgkDir), name+" "+time.Now().Format("20060102-150405")+filepath.Ext(path))
+		return set, archiveMove{From: path, To: archived}, nil
+	}
+	return set, archiveMove{}, &collisionError{Name: name, Path: path, Suggestion: freeClientName(tgkDir, name)}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"fastSwapper/utils"
+)
+
+// collisionSetup returns a Tgkdir whose active client Kunde A collides with an existing folder of that name.
+func collisionSetup(t *testing.T, strategy string) Settings {
+	t.Helper()
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin", CollisionStrategy: strategy},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	useSettings(t, set)
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "active"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde A"), map[string]string{"app.config": "existing"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "b"})
+	return set
+}
+
+func archivedEntries(t *testing.T, tgkDir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(archiveDirPath(tgkDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utils.Map(entries, func(e os.DirEntry) string { return e.Name() })
+}
+
+func Test_resolveCollision(t *testing.T) {
+	set := collisionSetup(t, COLLISION_ABORT)
+	_, _, err := resolveCollision(set, "Kunde B")
+	var collision *collisionError
+	if !errors.As(err, &collision) || collision.Suggestion != "Kunde A (1)" {
+		t.Errorf("Got %v, want a collision suggesting Kunde A (1)", err)
+	}
+
+	set.Defaults.CollisionStrategy = COLLISION_SUFFIX
+	resolved, archive, err := resolveCollision(set, "Kunde B")
+	if err != nil || resolved.ActiveSettings.OldDirectory != "Kunde A (1)" || archive.From != "" {
+		t.Errorf("Suffix strategy resolved to %q, %+v, %v", resolved.ActiveSettings.OldDirectory, archive, err)
+	}
+
+	set.Defaults.CollisionStrategy = COLLISION_ARCHIVE
+	resolved, archive, err = resolveCollision(set, "Kunde B")
+	if err != nil || resolved.ActiveSettings.OldDirectory != "Kunde A" || archive.From != filepath.Join(set.Defaults.Tgkdir, "Kunde A") {
+		t.Errorf("Archive strategy resolved to %q, %+v, %v", resolved.ActiveSettings.OldDirectory, archive, err)
+	}
+	// deciding is not doing
+	if !utils.Exists(filepath.Join(set.Defaults.Tgkdir, "Kunde A")) || len(archivedEntries(t, set.Defaults.Tgkdir)) != 0 {
+		t.Errorf("Resolving the collision already archived the folder")
+	}
+}
+
+func Test_archiveCollision_vetoedSwap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook needs a POSIX shell")
+	}
+	set := collisionSetup(t, COLLISION_ARCHIVE)
+	set.Hooks.PreSwap = "exit 1"
+	if err := swapDirectories(set, "Kunde B", SETTINGS_FILE_NAME); err == nil {
+		t.Fatal("Swap went through despite the failing pre-swap hook")
+	}
+	if !utils.Exists(filepath.Join(set.Defaults.Tgkdir, "Kunde A")) || len(archivedEntries(t, set.Defaults.Tgkdir)) != 0 {
+		t.Errorf("Vetoed swap archived the colliding folder")
+	}
+}
+
+func Test_archiveCollision_swap(t *testing.T) {
+	set := collisionSetup(t, COLLISION_ARCHIVE)
+	tgkDir := set.Defaults.Tgkdir
+	set, archive, err := resolveCollision(set, "Kunde B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a swap to a missing client stops before the archive is touched
+	if err := replaceAddinFolder(set, "Nobody", SETTINGS_FILE_NAME, archive); err == nil {
+		t.Fatal("Swap to a missing client did not fail")
+	}
+	if len(archivedEntries(t, tgkDir)) != 0 {
+		t.Errorf("Failed swap archived the colliding folder")
+	}
+	if err := replaceAddinFolder(set, "Kunde B", SETTINGS_FILE_NAME, archive); err != nil {
+		t.Fatal(err)
+	}
+	if archived := archivedEntries(t, tgkDir); len(archived) != 1 {
+		t.Errorf("Archive holds %v, want the colliding folder", archived)
+	}
+	for path, want := range map[string]string{"Addin": "b", "Kunde A": "active"} {
+		b, err := os.ReadFile(filepath.Join(tgkDir, path, "app.config"))
+		if err != nil || string(b) != want {
+			t.Errorf("%s holds %q, %v, want %q", path, b, err, want)
+		}
+	}
+}
+
+func Test_resolveCollision_store(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin", StorageMode: STORAGE_MODE_STORE},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde C"},
+	}
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "a"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde C"), map[string]string{"app.config": "c"})
+	if err := storeFolder(tgkDir, "Kunde C", filepath.Join(tgkDir, "Kunde C")); err != nil {
+		t.Fatal(err)
+	}
+	// Kunde A saved as Kunde C would overwrite the manifest of Kunde C
+	_, _, err := resolveCollision(set, "Kunde B")
+	var collision *collisionError
+	if !errors.As(err, &collision) || collision.Path != manifestPath(tgkDir, "Kunde C") {
+		t.Errorf("Got %v, want a collision with the manifest of Kunde C", err)
+	}
+	set.Defaults.CollisionStrategy = COLLISION_SUFFIX
+	if resolved, _, err := resolveCollision(set, "Kunde B"); err != nil || resolved.ActiveSettings.OldDirectory != "Kunde C (1)" {
+		t.Errorf("Suffix strategy resolved to %q, %v", resolved.ActiveSettings.OldDirectory, err)
+	}
+	set.Defaults.CollisionStrategy = COLLISION_ARCHIVE
+	_, archive, err := resolveCollision(set, "Kunde B")
+	if err != nil || archive.From != manifestPath(tgkDir, "Kunde C") || filepath.Ext(archive.To) != ".json" {
+		t.Errorf("Archive strategy planned %+v, %v", archive, err)
+	}
+	// the manifest the active client came from is its own
+	set.ActiveSettings.OldDirectory = "Kunde A"
+	if err := writeManifest(manifestPath(tgkDir, "Kunde A"), clientManifest{Client: "Kunde A"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, archive, err := resolveCollision(set, "Kunde B"); err != nil || archive.From != "" {
+		t.Errorf("Own manifest was taken for a collision: %+v, %v", archive, err)
+	}
+}
+
+func Test_resolveCollision_incoming(t *testing.T) {
+	// the settings are out of date: they say Kunde B is active, the addin folder holds Kunde A
+	set := collisionSetup(t, COLLISION_ARCHIVE)
+	tgkDir := set.Defaults.Tgkdir
+	set.ActiveSettings.OldDirectory = "Kunde B"
+	for _, strategy := range []string{COLLISION_ARCHIVE, COLLISION_SUFFIX, COLLISION_ABORT} {
+		set.Defaults.CollisionStrategy = strategy
+		_, archive, err := resolveCollision(set, "Kunde B")
+		var collision *collisionError
+		if !errors.As(err, &collision) || !collision.Incoming || archive.From != "" {
+			t.Errorf("%s strategy gave %+v, %v, want the swap refused", strategy, archive, err)
+		}
+	}
+	set.Defaults.CollisionStrategy = COLLISION_ARCHIVE
+	if err := swapDirectories(set, "Kunde B", SETTINGS_FILE_NAME); err == nil {
+		t.Fatal("Swapped in the client the active one would be saved as")
+	}
+	if len(archivedEntries(t, tgkDir)) != 0 {
+		t.Errorf("The client to swap in was archived")
+	}
+	for path, want := range map[string]string{"Addin": "active", "Kunde B": "b"} {
+		b, err := os.ReadFile(filepath.Join(tgkDir, path, "app.config"))
+		if err != nil || string(b) != want {
+			t.Errorf("%s holds %q, %v, want %q", path, b, err, want)
+		}
+	}
+}
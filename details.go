@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// Details of a single client for the side pane of the TUI. Collecting them hashes the whole client for the validation
+// status, so the TUI does it in the background and keeps the result until the list is reloaded.
+
+// the version of the addin as written into the VSTO deployment manifest, p.e.
+// <assemblyIdentity name="Tagetik.Excel.vsto" version="5.2.1.0" ... />
+var vstoVersionRegexp = regexp.MustCompile(`<assemblyIdentity\b[^>]*\sversion="([^"]+)"`)
+
+type clientDetails struct {
+	Client string
+	Stats  clientStats
+	Info   clientInfo
+	// Version is the one from the info if set, otherwise detected from the addin; VersionSource tells which
+	Version       string
+	VersionSource string
+	// empty if the client passed or had nothing to compare against, see Verified
+	Validation string
+	Verified   bool
+	Err        error
+	// the state of the files the details were collected from, see detailsStamp
+	stamp string
+}
+
+// detectVersion reads the version of the addin from the first VSTO manifest at the top of folderPath.
+func detectVersion(folderPath string) (string, string) {
+	entries, err := os.ReadDir(folderPath)
+	if err != nil {
+		return "", ""
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".vsto") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(folderPath, e.Name()))
+		if err != nil {
+			continue
+		}
+		if match := vstoVersionRegexp.FindSubmatch(b); match != nil {
+			return string(match[1]), e.Name()
+		}
+	}
+	return "", ""
+}
+
+// detailsStamp describes the entries the details of client are collected from by path, size and modification time.
+// As long as it stays the same the TUI keeps the details it has.
+func detailsStamp(set Settings, client string) string {
+	tgkDir := set.Defaults.Tgkdir
+	paths := []string{clientFolderPath(set, client), manifestPath(tgkDir, client), infoPath(tgkDir, client), checksumPath(tgkDir, client)}
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			lines = append(lines, fmt.Sprintf("%s %d %s", path, info.Size(), info.ModTime()))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func collectClientDetails(set Settings, client string) clientDetails {
+	tgkDir := set.Defaults.Tgkdir
+	d := clientDetails{
+		Client: client,
+		// taken first, a change while collecting makes the details look outdated rather than current
+		stamp: detailsStamp(set, client),
+		Stats: collectClientStats(set, client, lastUsedTimes(tgkDir)),
+		Info:  readClientInfo(tgkDir, client),
+	}
+	d.Version, d.VersionSource = d.Info.Version, "info"
+	if d.Version == "" {
+		d.Version, d.VersionSource = detectVersion(clientFolderPath(set, client))
+	}
+	report, ok, err := verifyClient(set, client)
+	switch {
+	case err != nil:
+		d.Err = err
+		d.Validation = err.Error()
+	case !ok:
+		d.Validation = "no checksums recorded"
+	case report.OK():
+		d.Verified = true
+		d.Validation = "matches its checksums"
+	default:
+		d.Validation = fmt.Sprintf("%d added, %d removed, %d modified", len(report.Added), len(report.Removed), len(report.Modified))
+	}
+	return d
+}
+
+// Lines returns the details as label/value lines.
+func (d clientDetails) Lines() [][2]string {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format("2006-01-02 15:04")
+	}
+	version := "unknown"
+	if d.Version != "" {
+		version = d.Version + " (" + d.VersionSource + ")"
+	}
+	lines := [][2]string{
+		{"size", utils.FormatBytes(uint64(d.Stats.Size))},
+		{"files", fmt.Sprint(d.Stats.Files)},
+		{"last modified", formatTime(d.Stats.Modified)},
+		{"last used", formatTime(d.Stats.LastUsed)},
+		{"version", version},
+	}
+	if d.Info.Customer != "" {
+		lines = append(lines, [2]string{"customer", d.Info.Customer})
+	}
+	if d.Info.Notes != "" {
+		lines = append(lines, [2]string{"notes", d.Info.Notes})
+	}
+	return append(lines, [2]string{"validation", d.Validation})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_detectVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantVersion string
+		wantSource  string
+	}{
+		{"no manifest", map[string]string{"addin.dll": "dll"}, "", ""},
+		{"manifest", map[string]string{"Tagetik.Excel.vsto": `<asmv1:assembly><assemblyIdentity name="Tagetik.Excel.vsto" version="5.2.1.0" publicKeyToken="0" /></asmv1:assembly>`}, "5.2.1.0", "Tagetik.Excel.vsto"},
+		{"extension in capitals", map[string]string{"Addin.VSTO": `<assemblyIdentity version="6.0" name="Addin"/>`}, "6.0", "Addin.VSTO"},
+		{"no version in manifest", map[string]string{"Addin.vsto": `<assemblyIdentity name="Addin"/>`}, "", ""},
+		{"element that only starts like the identity", map[string]string{"Addin.vsto": `<dependentAssembly><assemblyIdentityX version="1.0"/></dependentAssembly>`}, "", ""},
+		{"manifest in a subfolder", map[string]string{"sub/Addin.vsto": `<assemblyIdentity version="6.0"/>`}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			version, source := detectVersion(dir)
+			if version != tt.wantVersion || source != tt.wantSource {
+				t.Errorf("Got %q from %q, want %q from %q", version, source, tt.wantVersion, tt.wantSource)
+			}
+		})
+	}
+	if version, source := detectVersion(filepath.Join(t.TempDir(), "gone")); version != "" || source != "" {
+		t.Errorf("Missing folder gave %q from %q", version, source)
+	}
+}
+
+func Test_collectClientDetails(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "Addin.vsto": `<assemblyIdentity version="5.2"/>`})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"Addin.vsto": `<assemblyIdentity version="5.1"/>`, "app.config": "12345"})
+	if err := writeClientInfo(tgkDir, "Kunde B", clientInfo{Customer: "ACME", Version: "7.0"}); err != nil {
+		t.Fatal(err)
+	}
+	swapped := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	appendHistory(tgkDir, historyEntry{Time: swapped, From: "Kunde B", To: "Kunde A", Result: RESULT_SUCCESS})
+
+	// the active client is read from the addin folder and has never been recorded
+	d := collectClientDetails(set, "Kunde A")
+	if d.Version != "5.2" || d.VersionSource != "Addin.vsto" {
+		t.Errorf("Active client has version %q from %q", d.Version, d.VersionSource)
+	}
+	if d.Verified || d.Validation != "no checksums recorded" {
+		t.Errorf("Unrecorded client validated as %q", d.Validation)
+	}
+	if d.Stats.Files != 1 || !d.Stats.LastUsed.Equal(swapped) {
+		t.Errorf("Active client has stats %+v, want 1 file without the marker, last used %s", d.Stats, swapped)
+	}
+
+	// a version from the info wins over the detected one
+	if _, err := recordChecksums(set, "Kunde B"); err != nil {
+		t.Fatal(err)
+	}
+	d = collectClientDetails(set, "Kunde B")
+	if d.Version != "7.0" || d.VersionSource != "info" || d.Info.Customer != "ACME" {
+		t.Errorf("Kunde B has version %q from %q and info %+v", d.Version, d.VersionSource, d.Info)
+	}
+	if !d.Verified {
+		t.Errorf("Recorded client validated as %q", d.Validation)
+	}
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "changed", "new.config": "new"})
+	d = collectClientDetails(set, "Kunde B")
+	if d.Verified || d.Validation != "1 added, 0 removed, 1 modified" {
+		t.Errorf("Modified client validated as %q", d.Validation)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fastSwapper/utils"
+)
+
+// only these get a line by line diff, everything else is compared by size and hash
+var textConfigExtensions = []string{".config", ".xml", ".json", ".ini"}
+
+// clientSnapshot is what diffing needs to know about a client, whether it is a real folder or only lives in the store.
+type clientSnapshot struct {
+	manifest clientManifest
+	root     string // folder the files live in, empty for clients that only live in the store
+	tgkDir   string
+}
+
+type fileChange struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"` // "added", "removed" or "changed"
+	OldSize  int64  `json:"oldsize"`
+	NewSize  int64  `json:"newsize"`
+	OldHash  string `json:"oldhash,omitempty"`
+	NewHash  string `json:"newhash,omitempty"`
+	TextDiff string `json:"textdiff,omitempty"` // unified diff, only set for changed text config files
+}
+
+type diffResult struct {
+	A       string       `json:"a"`
+	B       string       `json:"b"`
+	Changes []fileChange `json:"changes"`
+}
+
+// loadSnapshot resolves name to a client folder in Tgkdir. Both the active client's name and Tgkfolder itself
+// resolve to the active folder.
+func loadSnapshot(set Settings, name string) (clientSnapshot, error) {
+	tgkDir := set.Defaults.Tgkdir
+	folder := clientFolderPath(set, name)
+	if name == set.Defaults.Tgkfolder {
+		folder = filepath.Join(tgkDir, set.Defaults.Tgkfolder)
+	}
+	if utils.Exists(folder) {
+		m, err := scanFolder(folder, name)
+		return clientSnapshot{manifest: m, root: folder, tgkDir: tgkDir}, err
+	}
+	m, err := readManifest(manifestPath(tgkDir, name))
+	if os.IsNotExist(err) {
+		return clientSnapshot{}, fmt.Errorf("Client %s exists neither as folder nor in the store.", name)
+	}
+	return clientSnapshot{manifest: m, tgkDir: tgkDir}, err
+}
+
+func (cs clientSnapshot) readFile(f manifestEntry) ([]byte, error) {
+	if cs.root != "" {
+		return os.ReadFile(filepath.Join(cs.root, filepath.FromSlash(f.Path)))
+	}
+	return os.ReadFile(blobPath(cs.tgkDir, f.Hash))
+}
+
+func isTextConfig(path string) bool {
+	return utils.ContainsString(textConfigExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
+func splitLines(b []byte) []string {
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffClients compares the clients a and b file by file.
+func diffClients(set Settings, a string, b string) (diffResult, error) {
+	result := diffResult{A: a, B: b, Changes: make([]fileChange, 0)}
+	snapA, err := loadSnapshot(set, a)
+	if err != nil {
+		return result, err
+	}
+	snapB, err := loadSnapshot(set, b)
+	if err != nil {
+		return result, err
+	}
+	filesA := make(map[string]manifestEntry)
+	for _, f := range snapA.manifest.Files {
+		filesA[f.Path] = f
+	}
+	for _, fb := range snapB.manifest.Files {
+		fa, ok := filesA[fb.Path]
+		delete(filesA, fb.Path)
+		if !ok {
+			result.Changes = append(result.Changes, fileChange{Path: fb.Path, Kind: "added", NewSize: fb.Size, NewHash: fb.Hash})
+			continue
+		}
+		if fa.Hash == fb.Hash {
+			continue
+		}
+		change := fileChange{Path: fb.Path, Kind: "changed", OldSize: fa.Size, NewSize: fb.Size, OldHash: fa.Hash, NewHash: fb.Hash}
+		if isTextConfig(fb.Path) {
+			contentA, err := snapA.readFile(fa)
+			if err != nil {
+				return result, err
+			}
+			contentB, err := snapB.readFile(fb)
+			if err != nil {
+				return result, err
+			}
+			change.TextDiff = utils.UnifiedDiff(splitLines(contentA), splitLines(contentB), a+"/"+fa.Path, b+"/"+fb.Path, 3)
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	for _, fa := range filesA {
+		result.Changes = append(result.Changes, fileChange{Path: fa.Path, Kind: "removed", OldSize: fa.Size, OldHash: fa.Hash})
+	}
+	sort.Slice(result.Changes, func(i, j int) bool { return result.Changes[i].Path < result.Changes[j].Path })
+	return result, nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+func (d diffResult) String() string {
+	if len(d.Changes) == 0 {
+		return fmt.Sprintf("%s and %s are identical.\n", d.A, d.B)
+	}
+	s := fmt.Sprintf("Differences from %s to %s:\n", d.A, d.B)
+	for _, c := range d.Changes {
+		switch c.Kind {
+		case "added":
+			s += fmt.Sprintf("  added    %s (%d bytes, %s)\n", c.Path, c.NewSize, shortHash(c.NewHash))
+		case "removed":
+			s += fmt.Sprintf("  removed  %s (%d bytes, %s)\n", c.Path, c.OldSize, shortHash(c.OldHash))
+		case "changed":
+			s += fmt.Sprintf("  changed  %s (%d -> %d bytes, %s -> %s)\n", c.Path, c.OldSize, c.NewSize, shortHash(c.OldHash), shortHash(c.NewHash))
+		}
+	}
+	for _, c := range d.Changes {
+		if c.TextDiff != "" {
+			s += "\n" + c.TextDiff
+		}
+	}
+	return s
+}
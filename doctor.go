@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fastSwapper/utils"
+)
+
+// doctor looks for the broken states we keep running into and knows a repair for each of them. Without --fix it only
+// reports, so the proposed repairs can be checked before anything is moved.
+
+const (
+	DOCTOR_COMMAND = "doctor"
+	FIX_FLAG       = "--fix"
+	// folders that cannot be clients are moved here by doctor --fix instead of being deleted
+	STRAY_DIR_NAME = "stray"
+)
+
+type doctorFinding struct {
+	Problem string `json:"problem"`
+	// empty if the problem has to be solved by hand
+	Repair string `json:"repair"`
+	Manual bool   `json:"manual"`
+	Fixed  bool   `json:"fixed"`
+	Error  string `json:"error,omitempty"`
+	fix    func() error
+}
+
+type doctorResult struct {
+	Findings []doctorFinding `json:"findings"`
+	// whether the repairs were applied
+	Fix bool `json:"fix"`
+}
+
+func strayDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), STRAY_DIR_NAME)
+}
+
+// diagnose checks set against what is on disk. The checks depend on each other, so it stops at the first problem that
+// makes the rest meaningless.
+func diagnose(set Settings, settingsFileName string) []doctorFinding {
+	findings := make([]doctorFinding, 0)
+	tgkDir := set.Defaults.Tgkdir
+	tgkfolder := set.Defaults.Tgkfolder
+	active := set.ActiveSettings.OldDirectory
+
+	// settings pointing at a Tgkdir that does not exist
+	if tgkDir == "" || !utils.Exists(tgkDir) {
+		f := doctorFinding{Problem: fmt.Sprintf("The settings point at the tagetik directory %q, which does not exist.", tgkDir)}
+		// Tgkdir is the directory holding the addin folder and the clients, not the addin folder itself
+		if utils.Exists(TGK_PARENT_DIR_DEFAULT_WIN) {
+			f.Repair = fmt.Sprintf("Point the settings at the default directory %s.", TGK_PARENT_DIR_DEFAULT_WIN)
+			f.fix = func() error {
+				setSettings(settingsFileName, "Tgkdir", TGK_PARENT_DIR_DEFAULT_WIN)
+				return nil
+			}
+		} else {
+			f.Repair = "Set the tagetik directory with fastSwapper -d <path>."
+			f.Manual = true
+		}
+		return append(findings, f)
+	}
+	tgkDirPath := filepath.Join(tgkDir, tgkfolder)
+	journal, unfinished, err := readJournal(tgkDir)
+	if err != nil {
+		slog.Warn("could not read swap journal", "error", err)
+	}
+
+	// Tgkfolder missing, usually after a swap that failed halfway
+	if tgkfolder == "" || !utils.Exists(tgkDirPath) {
+		f := doctorFinding{Problem: fmt.Sprintf("The addin folder %q is missing in %s.", tgkfolder, tgkDir)}
+		if unfinished {
+			f.Problem += fmt.Sprintf(" A swap from %s to %s stopped at: %s.", journal.From, journal.To, journal.Step)
+		}
+		// the settings are only updated at the very end of a swap, so whatever is found is put back as active
+		candidates := []string{filepath.Join(metaDirPath(tgkDir), "outgoing")}
+		if active != "" {
+			candidates = append(candidates, filepath.Join(tgkDir, active))
+		}
+		mismatched := make([]string, 0)
+		for _, candidate := range candidates {
+			if tgkfolder == "" || !utils.Exists(candidate) {
+				continue
+			}
+			// a folder marked as another client is that client, putting it back as active would mix them up
+			if marker := readMarker(candidate); marker != "" && marker != active {
+				mismatched = append(mismatched, fmt.Sprintf("%s is marked as %s", candidate, marker))
+				continue
+			}
+			from := candidate
+			f.Repair = fmt.Sprintf("Move %s back to %s as active client %s.", from, tgkDirPath, active)
+			f.fix = func() error {
+				err := os.Rename(from, tgkDirPath)
+				if err != nil {
+					return err
+				}
+				if err := writeMarker(tgkDirPath, active); err != nil {
+					slog.Warn("could not write client marker", "path", tgkDirPath, "error", err)
+				}
+				return clearJournal(tgkDir)
+			}
+			break
+		}
+		if f.fix == nil {
+			f.Repair = fmt.Sprintf("Copy a client folder to %s and name it with fastSwapper -o <client>.", tgkDirPath)
+			if len(mismatched) > 0 {
+				f.Repair = fmt.Sprintf("The settings say %s is active, but %s. Move the right folder to %s and name it with fastSwapper -o <client>.",
+					active, strings.Join(mismatched, " and "), tgkDirPath)
+			}
+			f.Manual = true
+		}
+		return append(findings, f)
+	}
+
+	// a journal left behind although the addin folder is in place
+	if unfinished {
+		findings = append(findings, doctorFinding{
+			Problem: fmt.Sprintf("A swap from %s to %s started %s never finished, it stopped at: %s.",
+				journal.From, journal.To, journal.Started.Local().Format("2006-01-02 15:04:05"), journal.Step),
+			Repair: "The addin folder is in place, remove the swap journal.",
+			fix:    func() error { return clearJournal(tgkDir) },
+		})
+	}
+
+	// the addin folder holds a different client than the settings say
+	marker := readMarker(tgkDirPath)
+	if marker != "" && marker != active && !clientExists(tgkDir, marker) {
+		m := marker
+		findings = append(findings, doctorFinding{
+			Problem: fmt.Sprintf("The settings say %s is active, but the addin folder is marked as %s.", active, marker),
+			Repair:  fmt.Sprintf("Set the active client to %s.", marker),
+			fix: func() error {
+				setActiveSettings(settingsFileName, "OldDirectory", m)
+				return nil
+			},
+		})
+		active = marker
+	}
+
+	// OldDirectory pointing at a folder that already exists, the next swap would rename onto it
+	if active != "" && utils.Exists(filepath.Join(tgkDir, active)) {
+		existing := filepath.Join(tgkDir, active)
+		newName := freeClientName(tgkDir, active)
+		findings = append(findings, doctorFinding{
+			Problem: fmt.Sprintf("The active client %s also exists as folder %s, the next swap would collide with it.", active, existing),
+			Repair:  fmt.Sprintf("Rename the existing folder to %s.", newName),
+			fix:     func() error { return os.Rename(existing, filepath.Join(tgkDir, newName)) },
+		})
+	}
+
+	// folders in Tgkdir that are listed as clients but cannot be one
+	dirs, err := utils.GetDirsInDir(tgkDir)
+	if err != nil {
+		slog.Warn("could not list the tagetik directory", "dir", tgkDir, "error", err)
+	}
+	for _, dir := range dirs {
+		if dir == tgkfolder || dir == META_DIR_NAME {
+			continue
+		}
+		reason := ""
+		if strings.HasPrefix(dir, ".") || strings.HasPrefix(dir, "$") {
+			reason = "is a hidden or system folder"
+		} else if !containsFiles(filepath.Join(tgkDir, dir)) {
+			reason = "contains no files"
+		}
+		if reason == "" {
+			continue
+		}
+		from := filepath.Join(tgkDir, dir)
+		to := filepath.Join(strayDirPath(tgkDir), dir)
+		findings = append(findings, doctorFinding{
+			Problem: fmt.Sprintf("%s is listed as a client but %s.", dir, reason),
+			Repair:  fmt.Sprintf("Move it to %s.", to),
+			fix: func() error {
+				err := os.MkdirAll(strayDirPath(tgkDir), 0755)
+				if err != nil {
+					return err
+				}
+				return os.Rename(from, to)
+			},
+		})
+	}
+	return findings
+}
+
+// containsFiles tells whether there is at least one regular file below dir.
+func containsFiles(dir string) bool {
+	found := false
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// runDoctor diagnoses set and, if fix is set, applies the repairs in order until one fails.
+func runDoctor(set Settings, settingsFileName string, fix bool) doctorResult {
+	r := doctorResult{Findings: diagnose(set, settingsFileName), Fix: fix}
+	if !fix {
+		return r
+	}
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		if f.fix == nil {
+			continue
+		}
+		err := f.fix()
+		if err != nil {
+			f.Error = err.Error()
+			slog.Error("doctor repair failed", "problem", f.Problem, "repair", f.Repair, "error", err)
+			// later repairs were planned on the assumption that this one works
+			break
+		}
+		f.Fixed = true
+		slog.Info("doctor repair applied", "problem", f.Problem, "repair", f.Repair)
+	}
+	return r
+}
+
+func (r doctorResult) String() string {
+	if len(r.Findings) == 0 {
+		return "No problems found.\n"
+	}
+	s := ""
+	open := 0
+	for i, f := range r.Findings {
+		state := "proposed"
+		switch {
+		case f.Fixed:
+			state = "fixed"
+		case f.Error != "":
+			state = "failed: " + f.Error
+		case f.Manual:
+			state = "to be done by hand"
+		}
+		if !f.Fixed {
+			open++
+		}
+		s += fmt.Sprintf("%d. %s\n   repair (%s): %s\n", i+1, f.Problem, state, f.Repair)
+	}
+	s += fmt.Sprintf("\n%d problems found, %d fixed.\n", len(r.Findings), len(r.Findings)-open)
+	if !r.Fix && open > 0 {
+		s += "Run fastSwapper doctor --fix to apply the proposed repairs.\n"
+	}
+	return s
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// doctorSetup returns a Tgkdir with an active client Kunde A and settings pointing at it.
+func doctorSetup(t *testing.T) (Settings, string) {
+	t.Helper()
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	useSettings(t, set)
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "b"})
+	return set, SETTINGS_FILE_NAME
+}
+
+func checkFixed(t *testing.T, r doctorResult, want int) {
+	t.Helper()
+	if len(r.Findings) != want {
+		t.Fatalf("Got %d findings, want %d:\n%s", len(r.Findings), want, r)
+	}
+	for _, f := range r.Findings {
+		if !f.Fixed {
+			t.Errorf("Not fixed:\n%s", r)
+		}
+	}
+}
+
+func Test_doctor_healthy(t *testing.T) {
+	set, settingsFile := doctorSetup(t)
+	writeFiles(t, filepath.Join(set.Defaults.Tgkdir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "a"})
+	if r := runDoctor(set, settingsFile, true); len(r.Findings) != 0 {
+		t.Errorf("Found problems in a healthy Tgkdir:\n%s", r)
+	}
+}
+
+func Test_doctor_missingTgkdir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("would look at the real default directory")
+	}
+	set, settingsFile := doctorSetup(t)
+	set.Defaults.Tgkdir = filepath.Join(t.TempDir(), "gone")
+	r := runDoctor(set, settingsFile, true)
+	if len(r.Findings) != 1 || !r.Findings[0].Manual {
+		t.Fatalf("Missing Tgkdir without a default directory is not to be fixed by hand:\n%s", r)
+	}
+	// outside of Windows the default is a relative name, created here in the working directory
+	if err := os.Mkdir(TGK_PARENT_DIR_DEFAULT_WIN, 0755); err != nil {
+		t.Fatal(err)
+	}
+	checkFixed(t, runDoctor(set, settingsFile, true), 1)
+	if got := getSettings(settingsFile).Tgkdir; got != TGK_PARENT_DIR_DEFAULT_WIN {
+		t.Errorf("Tgkdir was set to %q, want %q", got, TGK_PARENT_DIR_DEFAULT_WIN)
+	}
+}
+
+func Test_doctor_missingTgkfolder(t *testing.T) {
+	set, settingsFile := doctorSetup(t)
+	tgkDir := set.Defaults.Tgkdir
+	// a folder swap that stopped after renaming the outgoing folder
+	writeFiles(t, filepath.Join(tgkDir, "Kunde A"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "a"})
+	if err := writeJournal(tgkDir, swapJournal{Started: time.Now(), From: "Kunde A", To: "Kunde B", Step: "rename incoming folder"}); err != nil {
+		t.Fatal(err)
+	}
+	r := runDoctor(set, settingsFile, false)
+	if len(r.Findings) != 1 || r.Findings[0].Fixed || !utils.Exists(filepath.Join(tgkDir, "Kunde A")) {
+		t.Fatalf("Doctor without --fix changed something:\n%s", r)
+	}
+	checkFixed(t, runDoctor(set, settingsFile, true), 1)
+	if readMarker(filepath.Join(tgkDir, "Addin")) != "Kunde A" || utils.Exists(filepath.Join(tgkDir, "Kunde A")) {
+		t.Errorf("Kunde A was not moved back into the addin folder")
+	}
+	if _, ok, _ := readJournal(tgkDir); ok {
+		t.Errorf("Journal was not removed")
+	}
+}
+
+func Test_doctor_missingTgkfolder_otherClient(t *testing.T) {
+	set, settingsFile := doctorSetup(t)
+	tgkDir := set.Defaults.Tgkdir
+	// the folder named like the active client holds somebody else
+	writeFiles(t, filepath.Join(tgkDir, "Kunde A"), map[string]string{MARKER_FILE_NAME: "Kunde C\n", "app.config": "c"})
+	r := runDoctor(set, settingsFile, true)
+	if len(r.Findings) != 1 || !r.Findings[0].Manual || r.Findings[0].Fixed {
+		t.Fatalf("Folder of another client was not left to be sorted out by hand:\n%s", r)
+	}
+	if utils.Exists(filepath.Join(tgkDir, "Addin")) || !utils.Exists(filepath.Join(tgkDir, "Kunde A")) {
+		t.Errorf("Folder of another client was moved into the addin folder")
+	}
+}
+
+func Test_doctor_repairs(t *testing.T) {
+	set, settingsFile := doctorSetup(t)
+	tgkDir := set.Defaults.Tgkdir
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "a"})
+	// the next swap would rename the addin folder onto this one
+	writeFiles(t, filepath.Join(tgkDir, "Kunde A"), map[string]string{"app.config": "old a"})
+	if err := os.MkdirAll(filepath.Join(tgkDir, "Empty", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJournal(tgkDir, swapJournal{Started: time.Now(), From: "Kunde B", To: "Kunde A", Step: "update settings"}); err != nil {
+		t.Fatal(err)
+	}
+	checkFixed(t, runDoctor(set, settingsFile, true), 3)
+	if _, ok, _ := readJournal(tgkDir); ok {
+		t.Errorf("Journal was not removed")
+	}
+	if !utils.Exists(filepath.Join(tgkDir, "Kunde A (1)")) || utils.Exists(filepath.Join(tgkDir, "Kunde A")) {
+		t.Errorf("Colliding folder was not renamed to Kunde A (1)")
+	}
+	if !utils.Exists(filepath.Join(strayDirPath(tgkDir), "Empty")) || utils.Exists(filepath.Join(tgkDir, "Empty")) {
+		t.Errorf("Empty folder was not moved to %s", strayDirPath(tgkDir))
+	}
+	if r := runDoctor(set, settingsFile, false); len(r.Findings) != 0 {
+		t.Errorf("Problems left after the repairs:\n%s", r)
+	}
+}
+
+func Test_doctor_markerMismatch(t *testing.T) {
+	set, settingsFile := doctorSetup(t)
+	tgkDir := set.Defaults.Tgkdir
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde C\n", "app.config": "c"})
+	checkFixed(t, runDoctor(set, settingsFile, true), 1)
+	if got := getActiveSettings(settingsFile).OldDirectory; got != "Kunde C" {
+		t.Errorf("Active client is %q, want Kunde C from the marker", got)
+	}
+}
@@ -4,25 +4,66 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/oleiade/reflections"
 
+	"fastSwapper/tuiAssets"
 	"fastSwapper/utils"
 )
 
 type Settings struct {
 	Defaults       Default        `json:"defaults"`
 	ActiveSettings ActiveSettings `json:"activesettings"`
+	Hooks          Hooks          `json:"hooks"`
+	Tui            TuiSettings    `json:"tui"`
 }
 type Default struct {
 	Tgkdir    string `json:"tgkdir"`
 	Tgkfolder string `json:"tgkfolder"`
+	// STORAGE_MODE_FOLDERS or STORAGE_MODE_STORE, empty means folders
+	StorageMode string `json:"storagemode"`
+	// compare the incoming client against its recorded checksums before swapping it in
+	VerifyBeforeSwap bool `json:"verifybeforeswap"`
+	// what to do if a folder named like the outgoing client already exists, see collision.go. Empty means abort.
+	CollisionStrategy string `json:"collisionstrategy"`
 }
 type ActiveSettings struct {
 	OldDirectory string `json:"olddirectory"`
+	// the client that was active before the current one, target of swap back
+	PreviousDirectory string `json:"previousdirectory"`
+}
+
+// shell commands run around every swap, see hooks.go
+type Hooks struct {
+	PreSwap   string `json:"preswap"`
+	PostSwap  string `json:"postswap"`
+	OnFailure string `json:"onfailure"`
+	// how long each hook may run, p.e. "90s" or "2m". Empty means HOOK_DEFAULT_TIMEOUT.
+	Timeout string `json:"timeout"`
+}
+
+// choices made in the TUI that survive a restart
+type TuiSettings struct {
+	// one of sortModes, empty means by name
+	Sort string `json:"sort"`
+	// one of groupModes
+	Group string `json:"group"`
+	// the name of the chosen theme, see tuiAssets/themes.go. Color overrides its accent color, the color of the box and
+	// highlights, and Box is the name of the chosen box. Empty means the defaults.
+	Theme string `json:"theme"`
+	Color string `json:"color"`
+	Box   string `json:"box"`
+	// boxes of one's own by name, every segment a single character, p.e.
+	// "dots": {"topleft": ".", "topright": ".", "top": ".", "bottomleft": "'", "bottomright": "'", "bottom": ".", "left": ":", "right": ":"}
+	Boxes map[string]tuiAssets.Box `json:"boxes,omitempty"`
+	// overrides of the key bindings by mode and action, see keymap.go
+	Keys map[string]map[string][]string `json:"keys,omitempty"`
 }
 
 type helpInformation struct {
@@ -32,39 +73,98 @@ type helpInformation struct {
 func HelpInformation() helpInformation {
 	var help helpInformation
 	help.availableFlagsWithDesc = map[string]string{
-		"-d":  "Set default tagetik directory > fastSwapper -d <absolute path to directory>",
-		"-dw": "Reset default tagetik directory to the Windows one.",
-		"-tf": "Set Tagetik Addin Folder Name",
-		"-o":  "Set the name of the old directory, under this name the current Addin will be saved on swap. > fastSwapper -o <name of directory you want>",
-		"-h":  "Displays this help, use > fastSwapper -h <some other flag> to display only the help for a specific flag.",
-		"-sw": "Swap directories..",
+		"-d":        "Set default tagetik directory > fastSwapper -d <absolute path to directory>",
+		"-dw":       "Reset default tagetik directory to the Windows one.",
+		"-tf":       "Set Tagetik Addin Folder Name",
+		"-o":        "Set the name of the old directory, under this name the current Addin will be saved on swap. > fastSwapper -o <name of directory you want>",
+		"-h":        "Displays this help, use > fastSwapper -h <some other flag> to display only the help for a specific flag.",
+		"-sw":       "Swap directories > fastSwapper -sw <client> [--as <name>]. --as saves the active client under name instead of the one set with -o; it takes the rest of the line, so it comes last.",
+		"-sm":       "Set the storage mode > fastSwapper -sm <folders|store>. folders keeps every client as a plain folder, store keeps inactive clients deduplicated in a content-addressed store inside the tagetik directory.",
+		"store":     "Move inactive client folders into the store > fastSwapper store [<client>]. Without a client all inactive folders are moved.",
+		"gc":        "Remove blobs from the store that no client references anymore.",
+		"record":    "Record the SHA-256 checksums of a client folder > fastSwapper record <client>",
+		"verify":    "Compare a client folder against its recorded checksums and list added, removed and modified files > fastSwapper verify <client>",
+		"diff":      "Compare two client folders in the tagetik directory, the active one included > fastSwapper diff <client a> <client b>. Quote names containing spaces.",
+		"-hk":       "Set a hook command run around every swap > fastSwapper -hk <preswap|postswap|onfailure> <command>. Without a command the hook is removed. A failing preswap hook aborts the swap. fastSwapper -hk timeout <duration> sets how long a hook may run, 60s by default; a hook that takes longer fails.",
+		"history":   "Show the last swaps > fastSwapper history [<number of entries>], 20 entries by default.",
+		"back":      "Swap back to the client that was active before the current one.",
+		"undo":      "Swap back to the client that was active before the last recorded swap.",
+		"--verbose": "Write debug records to the log file next to the settings and, on the command line, also to the terminal. Can be combined with every other flag.",
+		"--plain":   "Start the TUI without colors, with ASCII borders and markers, for old consoles and screen readers. Also switched on by the NO_COLOR environment variable and TERM=dumb.",
+		"list":      "List the clients that can be swapped in and the active one.",
+		"status":    "Show the settings file, whether tgkdir and tgkfolder exist, the active client by settings and by marker, inactive clients, running Excel processes, an unfinished swap and the free disk space.",
+		"config":    "Show the current settings.",
+		"rename":    "Rename a client > fastSwapper rename <client> <new name>. Renaming the active client changes the name it is saved under on the next swap.",
+		"duplicate": "Copy a client under a new name > fastSwapper duplicate <client> <new name>",
+		"delete":    "Move an inactive client into the trash inside the tagetik directory > fastSwapper delete <client>",
+		"trash":     "List the clients in the trash.",
+		"restore":   "Move a client back out of the trash > fastSwapper restore <trash id|client>",
+		"info":      "Show or set the customer, version and notes of a client > fastSwapper info <client> [<customer|version|notes> <value>]. The TUI filter searches them.",
+		"doctor":    "Look for broken states (missing addin folder after a failed swap, active client colliding with an existing folder, missing tagetik directory, folders that cannot be clients) and propose a repair for each > fastSwapper doctor [--fix]. --fix applies the repairs.",
+		"--output":  "Output format of list, status, doctor, swap, rename, duplicate, delete, trash, restore, info, config and help, and of errors > fastSwapper --output <text|json> <flag>. json prints one object with a stable schema per call.",
+		"-vs":       "Verify the incoming client against its recorded checksums before every swap > fastSwapper -vs <on|off>",
+		"-cs":       "Set what a swap does if a folder named like the outgoing client already exists > fastSwapper -cs <abort|suffix|archive|prompt>. suffix saves it as \"name (1)\", archive moves the existing folder into the archive of the tagetik directory, prompt asks for a name in the TUI and aborts elsewhere.",
 	}
 	return help
 }
 
 const (
-	SETTINGS_FILE_NAME         string = "settings.json"
-	TGK_FOLDER_DEFAULT_WIN            = "Tagetik Excel .NET Client"
-	TGK_PARENT_DIR_DEFAULT_WIN        = "C:\\Tagetik\\"
-	TGK_DIR_DEFAULT_WIN               = TGK_PARENT_DIR_DEFAULT_WIN + TGK_FOLDER_DEFAULT_WIN
-	HELP_FLAG                         = "-h"
-	SWAP_FLAG                         = "-sw"
-	SET_DEFAULT_PATH_FLAG             = "-d"
-	SET_DEFAULT_WINPATH_FLAG          = "-dw"
-	SET_TGK_FOLDER_FLAG               = "-tf"
-	SET_OLDDIR_NAME_FLAG              = "-o"
-	EXCEL_PROCESS_NAME                = "EXCEL.EXE"
+	SETTINGS_FILE_NAME          string = "settings.json"
+	TGK_FOLDER_DEFAULT_WIN             = "Tagetik Excel .NET Client"
+	TGK_PARENT_DIR_DEFAULT_WIN         = "C:\\Tagetik\\"
+	TGK_DIR_DEFAULT_WIN                = TGK_PARENT_DIR_DEFAULT_WIN + TGK_FOLDER_DEFAULT_WIN
+	HELP_FLAG                          = "-h"
+	SWAP_FLAG                          = "-sw"
+	OUTGOING_NAME_FLAG                 = "--as"
+	SET_DEFAULT_PATH_FLAG              = "-d"
+	SET_DEFAULT_WINPATH_FLAG           = "-dw"
+	SET_TGK_FOLDER_FLAG                = "-tf"
+	SET_OLDDIR_NAME_FLAG               = "-o"
+	SET_STORAGE_MODE_FLAG              = "-sm"
+	STORE_COMMAND                      = "store"
+	GC_COMMAND                         = "gc"
+	RECORD_COMMAND                     = "record"
+	VERIFY_COMMAND                     = "verify"
+	SET_VERIFY_BEFORE_SWAP_FLAG        = "-vs"
+	DIFF_COMMAND                       = "diff"
+	SET_HOOK_FLAG                      = "-hk"
+	HISTORY_COMMAND                    = "history"
+	UNDO_COMMAND                       = "undo"
+	BACK_COMMAND                       = "back"
+	LIST_COMMAND                       = "list"
+	STATUS_COMMAND                     = "status"
+	CONFIG_COMMAND                     = "config"
+	EXCEL_PROCESS_NAME                 = "EXCEL.EXE"
+	META_DIR_NAME                      = ".fastSwapper"
+	STORAGE_MODE_FOLDERS               = "folders"
+	STORAGE_MODE_STORE                 = "store"
 )
 
+// characters that are not allowed in folder names on Windows
+var FORBIDDEN_CHARS [9]string = [9]string{"\\", "/", ":", "*", "?", "\"", "<", ">", "|"}
+
 func RunSwapper(args []string) error {
+	args, format, err := extractOutputFormat(args)
+	if err != nil {
+		emitError(OUTPUT_FLAG, err)
+		return err
+	}
+	outputFormat = format
 	// if settings.json does not exist, create it and put default values into it.
 	InitSettingsJSON()
+	// every command but the help reads the settings, which would end the program without telling why in the output
+	if len(args) > 1 && args[1] != HELP_FLAG {
+		if _, err = loadSettings(SETTINGS_FILE_NAME); err != nil {
+			emitError(commandName(args[1]), err)
+			return err
+		}
+	}
 	// try parsing the cli args which have been forwarded from the entry point. We omit arg 0 because thats only the path of the program.
-	err := parseCLIargs(args[1:])
-	if err != nil {
-		return err
+	err = parseCLIargs(args[1:])
+	if err != nil && len(args) > 1 {
+		emitError(commandName(args[1]), err)
 	}
-	return nil
+	return err
 }
 
 func InitSettingsJSON() {
@@ -84,12 +184,69 @@ func InitSettingsJSON() {
 }
 
 func parseCLIargs(args []string) error {
-	var (
-		err             error
-		FORBIDDEN_CHARS [9]string = [9]string{"\\", "/", ":", "*", "?", "\"", "<", ">", "|"}
-	)
+	var err error
 
 	help := HelpInformation()
+	// diff takes two names, those must not be concatenated like the argument of every other flag
+	if len(args) > 0 && args[0] == DIFF_COMMAND {
+		if len(args) != 3 {
+			err = newCLIError(ERR_USAGE, "Use fastSwapper diff <client a> <client b>, quote names containing spaces.")
+			return err
+		}
+		result, err := diffClients(GetCompleteSettings(SETTINGS_FILE_NAME), args[1], args[2])
+		if err != nil {
+			return err
+		}
+		emitResult(DIFF_COMMAND, result, result.String())
+		return err
+	}
+	// info sets a field, whose value is the rest of the line
+	if len(args) > 0 && args[0] == INFO_COMMAND {
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		switch {
+		case len(args) == 2:
+			info := readClientInfo(set.Defaults.Tgkdir, args[1])
+			emitResult("info", info, info.String())
+		case len(args) >= 4:
+			value, _ := utils.CombineString(args[3:])
+			info, err := setClientInfo(set, args[1], args[2], value)
+			if err != nil {
+				return err
+			}
+			emitResult("info", info, info.String())
+		default:
+			err = newCLIError(ERR_USAGE, "Use fastSwapper info <client> [<customer|version|notes> <value>], quote client names containing spaces.")
+		}
+		return err
+	}
+	// rename and duplicate take two names as well
+	if len(args) > 0 && (args[0] == RENAME_COMMAND || args[0] == DUPLICATE_COMMAND) {
+		if len(args) != 3 {
+			err = newCLIError(ERR_USAGE, "Use fastSwapper "+args[0]+" <client> <new name>, quote names containing spaces.")
+			return err
+		}
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		text := "Renamed %s to %s.\n"
+		if args[0] == RENAME_COMMAND {
+			err = renameClient(set, SETTINGS_FILE_NAME, args[1], args[2])
+		} else {
+			err = duplicateClient(set, args[1], args[2])
+			text = "Duplicated %s as %s.\n"
+		}
+		if err != nil {
+			return err
+		}
+		emitResult(args[0], clientActionResult{Client: args[1], Name: args[2]}, fmt.Sprintf(text, args[1], args[2]))
+		return err
+	}
+	// the name for the outgoing client is an option of swap, it must not end up in the concatenated client name
+	outgoingName := ""
+	if len(args) > 0 && args[0] == SWAP_FLAG {
+		args, outgoingName, err = extractTrailingOption(args, OUTGOING_NAME_FLAG)
+		if err != nil {
+			return err
+		}
+	}
 	// concatenate all args after 1 (including 1) into 1
 	if len(args) > 1 {
 		args[1], err = utils.CombineString(args[1:])
@@ -102,101 +259,342 @@ func parseCLIargs(args []string) error {
 		return err
 	}
 	if help.availableFlagsWithDesc[args[0]] == "" {
-		err = errors.New("Flag does not exist")
+		err = newCLIError(ERR_UNKNOWN_FLAG, "Flag does not exist")
 		return err
 	}
 	if args[0] == HELP_FLAG && len(args) == 2 {
 		if help.availableFlagsWithDesc[args[1]] == "" {
-			err = errors.New("Flag does not exist: " + args[1])
+			err = newCLIError(ERR_UNKNOWN_FLAG, "Flag does not exist: "+args[1])
 			return err
 		}
-		fmt.Printf("flag: %s\t%s\n", args[1], help.availableFlagsWithDesc[args[1]])
+		result := newHelpResult(help, args[1])
+		emitResult("help", result, result.String())
 		return err
 	} else if args[0] == HELP_FLAG && len(args) == 1 {
-		help := HelpInformation()
-		for k, v := range help.availableFlagsWithDesc {
-			fmt.Printf("flag: %s\t%s\n", k, v)
-		}
+		result := newHelpResult(help, "")
+		emitResult("help", result, result.String())
 		return err
 	}
 	if args[0] == SWAP_FLAG && len(args) == 2 {
-		// add checking for correct dir names here
-		err = swapDirectories(GetCompleteSettings(SETTINGS_FILE_NAME), args[1], SETTINGS_FILE_NAME)
+		start := time.Now()
+		err = SwapDirectoriesAs(args[1], outgoingName)
+		var collision *collisionError
+		if errors.As(err, &collision) {
+			return newCLIError(ERR_NAME_COLLISION, err.Error())
+		}
+		if err != nil {
+			return asCLIError(err, ERR_SWAP_FAILED)
+		}
+		emitSwapResult("swap", start)
 		return err
 	} else if args[0] == SWAP_FLAG && len(args) != 2 {
-		err = errors.New("Not the correct number of arguments supplied for -sw flag (1).")
+		err = newCLIError(ERR_USAGE, "Not the correct number of arguments supplied for -sw flag (1).")
 		return err
 	}
 	if len(args) > 2 {
-		err = errors.New("No flag supports more than 2 arguments. At most run > fastSwapper -flag <argument for flag>")
+		err = newCLIError(ERR_USAGE, "No flag supports more than 2 arguments. At most run > fastSwapper -flag <argument for flag>")
 		return err
 	}
 	// set default path flag expects the syntax of fastSwapper -d <path to directory>
 	if utils.ContainsString(args, SET_DEFAULT_PATH_FLAG) {
 		if len(args) < 2 {
-			err = errors.New("No path provided. Use fastSwapper -d <path to default dir>.")
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No path provided. Use fastSwapper -d <path to default dir>.")
 			return err
 		}
 		candidatePath := args[1]
 		if !utils.Exists(candidatePath) {
-			err = errors.New("Supplied path does not exist.")
+			err = newCLIError(ERR_NOT_FOUND, "Supplied path does not exist.")
 			return err
 		}
 		setSettings(SETTINGS_FILE_NAME, "Tgkdir", candidatePath)
+		emitResult(args[0], settingResult{Setting: "tgkdir", Value: candidatePath}, "")
+		return err
 	}
 	if utils.ContainsString(args, SET_DEFAULT_WINPATH_FLAG) {
 		if len(args) > 1 {
-			err = errors.New("Flag -dw does not take any additional arguments.")
+			err = newCLIError(ERR_USAGE, "Flag -dw does not take any additional arguments.")
 			return err
 		}
 		setSettings(SETTINGS_FILE_NAME, "Tgkdir", TGK_DIR_DEFAULT_WIN)
-		fmt.Printf("%s set as tagetik addin directory.\n", TGK_DIR_DEFAULT_WIN)
+		emitResult(args[0], settingResult{Setting: "tgkdir", Value: TGK_DIR_DEFAULT_WIN}, fmt.Sprintf("%s set as tagetik addin directory.\n", TGK_DIR_DEFAULT_WIN))
+		return err
 	}
 	if utils.ContainsString(args, SET_OLDDIR_NAME_FLAG) {
 		if len(args) < 2 {
-			err = errors.New("No name for the old directory provided. Use fastSwapper -o <name of the old directory>.")
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No name for the old directory provided. Use fastSwapper -o <name of the old directory>.")
 			return err
 		}
 		candidateName := args[1]
 		// we should probably also check for characters not supported in directory names..
 		if utils.ContainsStringWord(FORBIDDEN_CHARS[:], candidateName) {
-			err = errors.New("Supplied name must not contain forbidden character.")
+			err = newCLIError(ERR_INVALID_NAME, "Supplied name must not contain forbidden character.")
 			return err
 		}
 		setActiveSettings(SETTINGS_FILE_NAME, "OldDirectory", candidateName)
+		emitResult(args[0], settingResult{Setting: "olddirectory", Value: candidateName}, "")
 		return err
 	}
 	if utils.ContainsString(args, SET_TGK_FOLDER_FLAG) {
 		if len(args) < 2 {
-			err = errors.New("No name for the addin directory provided. Use fastSwapper -tf <name of the old directory>.")
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No name for the addin directory provided. Use fastSwapper -tf <name of the old directory>.")
 			return err
 		}
 		candidateName := args[1]
 		// we should probably also check for characters not supported in directory names..
 		if utils.ContainsStringWord(FORBIDDEN_CHARS[:], candidateName) {
-			err = errors.New("Supplied name must not contain forbidden character.")
+			err = newCLIError(ERR_INVALID_NAME, "Supplied name must not contain forbidden character.")
 			return err
 		}
 		setSettings(SETTINGS_FILE_NAME, "Tgkfolder", candidateName)
+		emitResult(args[0], settingResult{Setting: "tgkfolder", Value: candidateName}, "")
+		return err
+	}
+	if utils.ContainsString(args, SET_COLLISION_STRATEGY_FLAG) {
+		if len(args) < 2 || !utils.ContainsString(collisionStrategies, args[1]) {
+			err = newCLIError(ERR_INVALID_ARGUMENT, "Collision strategy must be one of abort, suffix, archive or prompt. Use fastSwapper -cs <abort|suffix|archive|prompt>.")
+			return err
+		}
+		setSettings(SETTINGS_FILE_NAME, "CollisionStrategy", args[1])
+		emitResult(args[0], settingResult{Setting: "collisionstrategy", Value: args[1]}, "")
+		return err
+	}
+	if utils.ContainsString(args, SET_STORAGE_MODE_FLAG) {
+		if len(args) < 2 || (args[1] != STORAGE_MODE_FOLDERS && args[1] != STORAGE_MODE_STORE) {
+			err = newCLIError(ERR_INVALID_ARGUMENT, "Storage mode must be either folders or store. Use fastSwapper -sm <folders|store>.")
+			return err
+		}
+		setSettings(SETTINGS_FILE_NAME, "StorageMode", args[1])
+		emitResult(args[0], settingResult{Setting: "storagemode", Value: args[1]}, "")
+		return err
+	}
+	if args[0] == LIST_COMMAND {
+		clients, err := DirectoriesInTgkDirExcludingTgkFolder()
+		if err != nil {
+			return err
+		}
+		result := listResult{Active: GetActiveVersion(), Clients: clients}
+		emitResult("list", result, result.String())
+		return err
+	}
+	if args[0] == STATUS_COMMAND {
+		result := newStatusResult(GetCompleteSettings(SETTINGS_FILE_NAME))
+		emitResult("status", result, result.String())
+		return err
+	}
+	if args[0] == DELETE_COMMAND || args[0] == RESTORE_COMMAND {
+		if len(args) < 2 {
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No client provided. Use fastSwapper "+args[0]+" <client>.")
+			return err
+		}
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		if args[0] == DELETE_COMMAND {
+			entry, err := deleteClient(set, SETTINGS_FILE_NAME, args[1])
+			if err != nil {
+				return err
+			}
+			emitResult("delete", clientActionResult{Client: entry.Client, Name: entry.ID}, fmt.Sprintf("Moved %s to the trash as %s, get it back with fastSwapper restore %s.\n", entry.Client, entry.ID, entry.ID))
+			return err
+		}
+		entry, err := restoreClient(set, args[1])
+		if err != nil {
+			return err
+		}
+		emitResult("restore", clientActionResult{Client: entry.Client, Name: entry.ID}, fmt.Sprintf("Restored %s.\n", entry.Client))
+		return err
+	}
+	if args[0] == TRASH_COMMAND {
+		result := trashResult{Entries: readTrash(GetTgkDir())}
+		emitResult("trash", result, result.String())
+		return err
+	}
+	if args[0] == DOCTOR_COMMAND {
+		if len(args) == 2 && args[1] != FIX_FLAG {
+			err = newCLIError(ERR_INVALID_ARGUMENT, "Use fastSwapper doctor [--fix].")
+			return err
+		}
+		result := runDoctor(GetCompleteSettings(SETTINGS_FILE_NAME), SETTINGS_FILE_NAME, len(args) == 2)
+		emitResult("doctor", result, result.String())
+		return err
+	}
+	if args[0] == CONFIG_COMMAND {
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		text, err := json.MarshalIndent(set, "", "    ")
+		if err != nil {
+			return err
+		}
+		emitResult("config", set, string(text)+"\n")
+		return err
+	}
+	if args[0] == STORE_COMMAND {
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		toStore, err := DirectoriesInTgkDirExcludingTgkFolder()
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			toStore = []string{args[1]}
+		}
+		result := storeResult{Stored: make([]string, 0)}
+		for _, client := range toStore {
+			folderPath := filepath.Join(set.Defaults.Tgkdir, client)
+			// clients which are already in the store only show up in the list in store mode
+			if !utils.Exists(folderPath) {
+				continue
+			}
+			err = storeFolder(set.Defaults.Tgkdir, client, folderPath)
+			if err != nil {
+				// what made it into the store before stays there
+				return &cliError{Code: ERR_OPERATION_FAILED, Message: err.Error(), Details: result}
+			}
+			result.Stored = append(result.Stored, client)
+		}
+		emitResult(STORE_COMMAND, result, result.String())
+		return err
+	}
+	if args[0] == GC_COMMAND {
+		removed, freed, err := storeGC(GetTgkDir())
+		if err != nil {
+			return err
+		}
+		emitResult(GC_COMMAND, gcResult{Removed: removed, Freed: freed}, fmt.Sprintf("Removed %d unreferenced blobs, %d bytes freed.\n", removed, freed))
+		return err
+	}
+	if args[0] == RECORD_COMMAND || args[0] == VERIFY_COMMAND {
+		if len(args) < 2 {
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No client provided. Use fastSwapper "+args[0]+" <client>.")
+			return err
+		}
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		if args[0] == RECORD_COMMAND {
+			m, err := recordChecksums(set, args[1])
+			if err != nil {
+				return err
+			}
+			emitResult(RECORD_COMMAND, recordResult{Client: args[1], Files: len(m.Files)}, fmt.Sprintf("Recorded checksums of %d files for %s.\n", len(m.Files), args[1]))
+			return err
+		}
+		report, ok, err := verifyClient(set, args[1])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			err = newCLIError(ERR_NOT_FOUND, "No checksums recorded for "+args[1]+". Use fastSwapper record <client> first.")
+			return err
+		}
+		if !report.OK() {
+			// the report is the message, so it is printed once in either format
+			err = &cliError{Code: ERR_VERIFICATION_FAILED, Message: report.String(), Details: report}
+			return err
+		}
+		emitResult(VERIFY_COMMAND, report, report.String()+"\n")
+		return err
+	}
+	if args[0] == HISTORY_COMMAND {
+		count := 20
+		if len(args) == 2 {
+			count, err = strconv.Atoi(args[1])
+			if err != nil || count < 1 {
+				err = newCLIError(ERR_INVALID_ARGUMENT, "Number of entries must be a positive number.")
+				return err
+			}
+		}
+		entries, err := readHistory(GetTgkDir())
+		if err != nil {
+			return err
+		}
+		if len(entries) > count {
+			entries = entries[len(entries)-count:]
+		}
+		result := historyResult{Entries: entries}
+		emitResult(HISTORY_COMMAND, result, result.String())
+		return err
+	}
+	if args[0] == BACK_COMMAND {
+		if len(args) > 1 {
+			err = newCLIError(ERR_USAGE, "back does not take any additional arguments.")
+			return err
+		}
+		start := time.Now()
+		err = SwapBack()
+		if err != nil {
+			return asCLIError(err, ERR_SWAP_FAILED)
+		}
+		emitSwapResult(BACK_COMMAND, start)
+		return err
+	}
+	if args[0] == UNDO_COMMAND {
+		if len(args) > 1 {
+			err = newCLIError(ERR_USAGE, "undo does not take any additional arguments.")
+			return err
+		}
+		start := time.Now()
+		err = UndoLastSwap()
+		if err != nil {
+			return asCLIError(err, ERR_SWAP_FAILED)
+		}
+		emitSwapResult(UNDO_COMMAND, start)
+		return err
+	}
+	if utils.ContainsString(args, SET_HOOK_FLAG) {
+		if len(args) < 2 {
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No hook provided. Use fastSwapper -hk <preswap|postswap|onfailure> <command>.")
+			return err
+		}
+		// the command may contain spaces itself, only the first word names the hook
+		name, command, _ := strings.Cut(args[1], " ")
+		field, ok := hookFields[name]
+		if !ok {
+			err = newCLIError(ERR_INVALID_ARGUMENT, "Hook must be one of preswap, postswap, onfailure or timeout.")
+			return err
+		}
+		if name == HOOK_TIMEOUT && command != "" {
+			if timeout, parseErr := time.ParseDuration(command); parseErr != nil || timeout <= 0 {
+				err = newCLIError(ERR_INVALID_ARGUMENT, "Timeout must be a positive duration like 90s or 2m. Use fastSwapper -hk timeout <duration>.")
+				return err
+			}
+		}
+		setHooks(SETTINGS_FILE_NAME, field, command)
+		emitResult(args[0], settingResult{Setting: "hooks." + name, Value: command}, "")
+		return err
+	}
+	if utils.ContainsString(args, SET_VERIFY_BEFORE_SWAP_FLAG) {
+		if len(args) < 2 || (args[1] != "on" && args[1] != "off") {
+			err = newCLIError(ERR_INVALID_ARGUMENT, "Use fastSwapper -vs <on|off>.")
+			return err
+		}
+		setSettings(SETTINGS_FILE_NAME, "VerifyBeforeSwap", args[1] == "on")
+		emitResult(args[0], settingResult{Setting: "verifybeforeswap", Value: args[1] == "on"}, "")
 		return err
 	}
 	return err
 }
 
-func unmarshalSettingsJson(filename string) Settings {
-	jsonFile, err := os.Open(filename)
-	if err != nil {
-		log.Fatal(err)
+// loadSettings reads the settings file, a missing file is ERR_NOT_FOUND and one that cannot be read or parsed
+// ERR_INVALID_SETTINGS.
+func loadSettings(filename string) (Settings, error) {
+	slog.Debug("reading settings", "file", filename)
+	var settings Settings
+	byteResult, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+		return settings, newCLIError(ERR_NOT_FOUND, fmt.Sprintf("There is no settings file %s.", filename))
 	}
-	defer jsonFile.Close()
-	byteResult, err := io.ReadAll(jsonFile)
 	if err != nil {
-		log.Fatal(err)
+		return settings, newCLIError(ERR_INVALID_SETTINGS, fmt.Sprintf("Could not read the settings file %s: %s", filename, err))
 	}
-	var settings Settings
 	err = json.Unmarshal(byteResult, &settings)
 	if err != nil {
-		log.Fatal(err)
+		return settings, newCLIError(ERR_INVALID_SETTINGS, fmt.Sprintf("Could not parse the settings file %s: %s", filename, err))
+	}
+	return settings, nil
+}
+
+func unmarshalSettingsJson(filename string) Settings {
+	settings, err := loadSettings(filename)
+	if err != nil {
+		fatal("could not read settings", err)
 	}
 	return settings
 }
@@ -204,12 +602,13 @@ func unmarshalSettingsJson(filename string) Settings {
 func updateSettingsJson(filename string, data Settings) {
 	modifiedJson, err := json.MarshalIndent(data, "", "    ")
 	if err != nil {
-		log.Fatal(err)
+		fatal("could not serialise settings", err)
 	}
 	err = os.WriteFile(filename, modifiedJson, 0644)
 	if err != nil {
-		log.Fatal(err)
+		fatal("could not write settings", err)
 	}
+	slog.Info("settings written", "file", filename)
 }
 
 func GetCompleteSettings(filename string) Settings {
@@ -224,13 +623,18 @@ func getActiveSettings(filename string) ActiveSettings {
 	return unmarshalSettingsJson(filename).ActiveSettings
 }
 
-func setSettings(filename string, defaultToChange string, newValue string) {
+func getTuiSettings(filename string) TuiSettings {
+	return unmarshalSettingsJson(filename).Tui
+}
+
+func setSettings(filename string, defaultToChange string, newValue interface{}) {
 	unmarshaledJson := unmarshalSettingsJson(filename)
 
 	err := reflections.SetField(&unmarshaledJson.Defaults, defaultToChange, newValue)
 	if err != nil {
-		log.Fatal(err)
+		fatal("could not change setting", err)
 	}
+	slog.Info("setting changed", "section", "defaults", "field", defaultToChange, "value", newValue)
 	updateSettingsJson(filename, unmarshaledJson)
 }
 
@@ -239,11 +643,42 @@ func setActiveSettings(filename string, defaultToChange string, newValue string)
 
 	err := reflections.SetField(&unmarshaledJson.ActiveSettings, defaultToChange, newValue)
 	if err != nil {
-		log.Fatal(err)
+		fatal("could not change setting", err)
+	}
+	slog.Info("setting changed", "section", "activesettings", "field", defaultToChange, "value", newValue)
+	updateSettingsJson(filename, unmarshaledJson)
+}
+
+func setHooks(filename string, hookToChange string, newValue string) {
+	unmarshaledJson := unmarshalSettingsJson(filename)
+
+	err := reflections.SetField(&unmarshaledJson.Hooks, hookToChange, newValue)
+	if err != nil {
+		fatal("could not change setting", err)
 	}
+	slog.Info("setting changed", "section", "hooks", "field", hookToChange, "value", newValue)
 	updateSettingsJson(filename, unmarshaledJson)
 }
 
+func setTuiSettings(filename string, fieldToChange string, newValue string) {
+	unmarshaledJson := unmarshalSettingsJson(filename)
+
+	err := reflections.SetField(&unmarshaledJson.Tui, fieldToChange, newValue)
+	if err != nil {
+		fatal("could not change setting", err)
+	}
+	slog.Info("setting changed", "section", "tui", "field", fieldToChange, "value", newValue)
+	updateSettingsJson(filename, unmarshaledJson)
+}
+
+func settingsFilePath() string {
+	path, err := filepath.Abs(SETTINGS_FILE_NAME)
+	if err != nil {
+		return SETTINGS_FILE_NAME
+	}
+	return path
+}
+
 func GetActiveVersion() string {
 	set := getActiveSettings(SETTINGS_FILE_NAME)
 	return set.OldDirectory
@@ -259,50 +694,237 @@ func GetTgkDir() string {
 	return set.Tgkdir
 }
 
-func DirectoriesInTgkDirExcludingTgkFolder() []string {
+func DirectoriesInTgkDirExcludingTgkFolder() ([]string, error) {
 	tgkDir := GetTgkDir()
 	tgkFolder := GetTgkFolder()
-	dirs := utils.GetDirsInDir(tgkDir)
+	dirs, err := utils.GetDirsInDir(tgkDir)
+	if err != nil {
+		return nil, newCLIError(ERR_NOT_FOUND, fmt.Sprintf("Could not list the tagetik directory %s: %s", tgkDir, err))
+	}
 	dirsWithOutTgkFolder := utils.Remove(dirs, tgkFolder)
-	return dirsWithOutTgkFolder
+	dirsWithOutTgkFolder = utils.Remove(dirsWithOutTgkFolder, META_DIR_NAME)
+	// in store mode inactive clients mostly live in the store only. The active client keeps its manifest, skip it.
+	if getSettings(SETTINGS_FILE_NAME).StorageMode == STORAGE_MODE_STORE {
+		active := GetActiveVersion()
+		for _, client := range storedClients(tgkDir) {
+			if client != active && !utils.ContainsString(dirsWithOutTgkFolder, client) {
+				dirsWithOutTgkFolder = append(dirsWithOutTgkFolder, client)
+			}
+		}
+	}
+	return dirsWithOutTgkFolder, nil
 }
 
 // shadows private method swapDirectories in order to let the caller not care about the settings file
 func SwapDirectories(newDirName string) error {
-	return swapDirectories(GetCompleteSettings(SETTINGS_FILE_NAME), newDirName, SETTINGS_FILE_NAME)
+	return SwapDirectoriesAs(newDirName, "")
+}
+
+// SwapDirectoriesAs swaps in newDirName and saves the active client as outgoingName. An empty outgoingName keeps
+// the name from the settings (OldDirectory).
+func SwapDirectoriesAs(newDirName string, outgoingName string) error {
+	set := GetCompleteSettings(SETTINGS_FILE_NAME)
+	if outgoingName != "" {
+		if err := validClientName(outgoingName); err != nil {
+			return err
+		}
+		set.ActiveSettings.OldDirectory = outgoingName
+	}
+	return swapDirectories(set, newDirName, SETTINGS_FILE_NAME)
+}
+
+// extractOption removes "name <value>" (or "name=<value>") from args and returns the value, empty if it is not given.
+func extractOption(args []string, name string) ([]string, string, error) {
+	value := ""
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == name:
+			if i+1 >= len(args) {
+				return args, value, newCLIError(ERR_MISSING_ARGUMENT, "No value provided for "+name+".")
+			}
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], name+"="):
+			value = strings.TrimPrefix(args[i], name+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, value, nil
 }
 
-// this swaps two folders
+// extractTrailingOption removes "name <value ...>" (or "name=<value ...>") and everything after it from args. The value
+// is the rest of the line joined by spaces, the same way the argument of a flag is, so "--as Kunde Alt" is "Kunde Alt".
+func extractTrailingOption(args []string, name string) ([]string, string, error) {
+	for i, arg := range args {
+		if arg != name && !strings.HasPrefix(arg, name+"=") {
+			continue
+		}
+		words := append([]string{strings.TrimPrefix(strings.TrimPrefix(arg, name), "=")}, args[i+1:]...)
+		value, err := utils.CombineString(words)
+		value = strings.TrimSpace(value)
+		if err == nil && value == "" {
+			err = newCLIError(ERR_MISSING_ARGUMENT, "No value provided for "+name+".")
+		}
+		return args[:i], value, err
+	}
+	return args, "", nil
+}
+
+// SwapBack swaps in the client that was active before the current one.
+func SwapBack() error {
+	set := GetCompleteSettings(SETTINGS_FILE_NAME)
+	if set.ActiveSettings.PreviousDirectory == "" {
+		return errors.New("There is no previous client to swap back to yet.")
+	}
+	return swapDirectories(set, set.ActiveSettings.PreviousDirectory, SETTINGS_FILE_NAME)
+}
+
+// this swaps two folders and runs the configured hooks around it
 // needs refactoring, why the heck am I passing in a Settings obj and settingsFileName?
 func swapDirectories(set Settings, newDirName string, settingsFileName string) error {
 	// the fact that I have to pass in the settings file name here is bad imo.. maybe refactor lator.
-	var err error
+	tgkDir := set.Defaults.Tgkdir
+	// nothing has been touched yet, a collision simply ends the swap here
+	set, archive, err := resolveCollision(set, newDirName)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	swapped := false
+	env := hookEnv(set, newDirName)
+	slog.Info("swap started", "from", set.ActiveSettings.OldDirectory, "to", newDirName, "storagemode", set.Defaults.StorageMode)
+	// a failing pre-swap hook vetoes the swap before anything was touched
+	err = runHook(HOOK_PRE_SWAP, set.Hooks.PreSwap, env, hookTimeout(set.Hooks))
+	if err == nil {
+		err = replaceAddinFolder(set, newDirName, settingsFileName, archive)
+		swapped = err == nil
+	}
+	// the post-swap hook runs before Excel comes back up, so it can still clean caches the addin would lock
+	if err == nil {
+		err = runHook(HOOK_POST_SWAP, set.Hooks.PostSwap, env, hookTimeout(set.Hooks))
+	}
+	if err == nil {
+		// Terminate MS Excel
+		// err = KillProcessByName(EXCEL_PROCESS_NAME)
+		err = utils.RestartProgramByName("excel")
+	}
+	entry := historyEntry{
+		Time:       start,
+		User:       currentUser(),
+		From:       set.ActiveSettings.OldDirectory,
+		To:         newDirName,
+		DurationMs: time.Since(start).Milliseconds(),
+		Result:     RESULT_SUCCESS,
+	}
+	if err != nil {
+		entry.Result = RESULT_FAILURE
+		if swapped {
+			entry.Result = RESULT_PARTIAL
+		}
+		entry.Error = err.Error()
+		appendHistory(tgkDir, entry)
+		slog.Error("swap failed", "from", set.ActiveSettings.OldDirectory, "to", newDirName, "result", entry.Result, "error", err)
+		runHook(HOOK_ON_FAILURE, set.Hooks.OnFailure, append(env, "FASTSWAPPER_ERROR="+err.Error()), hookTimeout(set.Hooks))
+		return err
+	}
+	appendHistory(tgkDir, entry)
+	slog.Info("swap finished", "from", set.ActiveSettings.OldDirectory, "to", newDirName, "durationms", entry.DurationMs)
+	return nil
+}
+
+// replaceAddinFolder does the actual work of a swap: the active client is moved out of Tgkfolder and newDirName in.
+// archive moves a client colliding with the outgoing one out of the way, see resolveCollision.
+func replaceAddinFolder(set Settings, newDirName string, settingsFileName string, archive archiveMove) (err error) {
 	oldDirName := set.ActiveSettings.OldDirectory
 	tgkDir := set.Defaults.Tgkdir
 	tgkfolder := set.Defaults.Tgkfolder
-	// add logic here that does the following:
-	newDirPath := tgkDir + "\\" + newDirName
-	if !utils.Exists(newDirPath) {
+	// 0. optionally make sure the incoming client is still what was recorded
+	if set.Defaults.VerifyBeforeSwap {
+		slog.Debug("swap step", "step", "verify incoming client", "client", newDirName)
+		err = verifyBeforeSwap(set, newDirName)
+		if err != nil {
+			return err
+		}
+	}
+	// render the config templates of the new client up front, a broken template should not leave a half done swap
+	rendered, err := renderTemplates(tgkDir, newDirName)
+	if err != nil {
+		return err
+	}
+	tgkDirPath := filepath.Join(tgkDir, tgkfolder)
+	// from here on folders are moved, the journal tells what was going on if we never get to the end
+	journal := swapJournal{Started: time.Now(), User: currentUser(), From: oldDirName, To: newDirName, Tgkfolder: tgkfolder, StorageMode: set.Defaults.StorageMode}
+	step := func(name string) {
+		journal.Step = name
+		if err := writeJournal(tgkDir, journal); err != nil {
+			slog.Warn("could not write swap journal", "error", err)
+		}
+	}
+	defer func() {
+		// a swap that failed but left the addin folder in place has nothing to repair
+		if err == nil || utils.Exists(tgkDirPath) {
+			clearJournal(tgkDir)
+		}
+	}()
+	// a swap to a client that does not exist must leave the active one exactly as it is
+	exists := utils.Exists(filepath.Join(tgkDir, newDirName))
+	if set.Defaults.StorageMode == STORAGE_MODE_STORE {
+		exists = clientExists(tgkDir, newDirName)
+	}
+	if !exists {
 		err = errors.New("Folder to swap in does not exist.")
 		return err
 	}
-	tgkDirPath := tgkDir + "\\" + tgkfolder
-	oldDirPath := tgkDir + "\\" + oldDirName
-	// 1. rename tgk dir to olddir
-	err = os.Rename(tgkDirPath, oldDirPath)
+	if archive.From != "" {
+		step("archive colliding client")
+		err = archive.apply()
+		if err != nil {
+			return err
+		}
+	}
+	// put back the config files the outgoing client had rendered, so it is stored the way it came in
+	step("restore templates")
+	err = restoreTemplates(tgkDir, tgkDirPath)
 	if err != nil {
 		return err
 	}
-	// 2. rename newDir folder to tgk dir
-	err = os.Rename(newDirPath, tgkDirPath)
+	if set.Defaults.StorageMode == STORAGE_MODE_STORE {
+		// 1.+2. materialise the new client from the store instead of renaming folders
+		err = swapFromStore(set, newDirName, step)
+		if err != nil {
+			return err
+		}
+	} else {
+		newDirPath := filepath.Join(tgkDir, newDirName)
+		oldDirPath := filepath.Join(tgkDir, oldDirName)
+		// 1. rename tgk dir to olddir
+		step("rename outgoing folder")
+		slog.Info("swap step", "step", "rename outgoing folder", "from", tgkDirPath, "to", oldDirPath)
+		err = os.Rename(tgkDirPath, oldDirPath)
+		if err != nil {
+			return err
+		}
+		// 2. rename newDir folder to tgk dir
+		step("rename incoming folder")
+		slog.Info("swap step", "step", "rename incoming folder", "from", newDirPath, "to", tgkDirPath)
+		err = os.Rename(newDirPath, tgkDirPath)
+		if err != nil {
+			return err
+		}
+	}
+	// 3. update oldDir setting with newDir and mark the folder as belonging to newDir
+	step("update settings")
+	setActiveSettings(settingsFileName, "OldDirectory", newDirName)
+	setActiveSettings(settingsFileName, "PreviousDirectory", oldDirName)
+	if err := writeMarker(tgkDirPath, newDirName); err != nil {
+		slog.Warn("could not write client marker", "path", tgkDirPath, "error", err)
+	}
+	// 4. render the new client's config templates into the addin folder
+	err = applyTemplates(tgkDir, tgkDirPath, newDirName, rendered)
 	if err != nil {
 		return err
 	}
-	// 3. update oldDir setting with newDir
-	setActiveSettings(settingsFileName, "OldDirectory", newDirName)
-	// Terminate MS Excel
-	// err = KillProcessByName(EXCEL_PROCESS_NAME)
-	err = utils.RestartProgramByName("excel")
-	// Penultimate return
 	return err
 }
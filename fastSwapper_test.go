@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_extractTrailingOption(t *testing.T) {
+	tests := []struct {
+		args      []string
+		wantRest  []string
+		wantValue string
+		wantErr   bool
+	}{
+		{[]string{"-sw", "Kunde", "B"}, []string{"-sw", "Kunde", "B"}, "", false},
+		{[]string{"-sw", "Kunde", "B", "--as", "Kunde", "Alt"}, []string{"-sw", "Kunde", "B"}, "Kunde Alt", false},
+		{[]string{"-sw", "Kunde B", "--as", "Kunde Alt"}, []string{"-sw", "Kunde B"}, "Kunde Alt", false},
+		{[]string{"-sw", "B", "--as=Kunde", "Alt"}, []string{"-sw", "B"}, "Kunde Alt", false},
+		{[]string{"-sw", "B", "--as"}, nil, "", true},
+		{[]string{"-sw", "B", "--as="}, nil, "", true},
+	}
+	for _, tt := range tests {
+		rest, value, err := extractTrailingOption(tt.args, OUTGOING_NAME_FLAG)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tt.args, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if value != tt.wantValue || !reflect.DeepEqual(rest, tt.wantRest) {
+			t.Errorf("%q: got %q and %q, want %q and %q", tt.args, rest, value, tt.wantRest, tt.wantValue)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// Every swap attempt is appended as one JSON object per line to
+//
+//	<Tgkdir>/.fastSwapper/history.jsonl
+
+const (
+	// everything went through
+	RESULT_SUCCESS = "success"
+	// the folders were swapped, but a later step (post-swap hook, restarting Excel) failed
+	RESULT_PARTIAL = "partial"
+	// nothing was swapped
+	RESULT_FAILURE = "failure"
+)
+
+type historyEntry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	DurationMs int64     `json:"durationms"`
+	Result     string    `json:"result"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func historyPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "history.jsonl")
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USERNAME"); name != "" {
+		return name
+	}
+	return os.Getenv("USER")
+}
+
+// appendHistory records a swap attempt. The history must never break a swap, so errors writing it are ignored.
+func appendHistory(tgkDir string, e historyEntry) {
+	if os.MkdirAll(metaDirPath(tgkDir), 0755) != nil {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(historyPath(tgkDir), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(b, '\n'))
+}
+
+// readHistory returns all recorded swap attempts, oldest first. Lines that cannot be parsed are skipped.
+func readHistory(tgkDir string) ([]historyEntry, error) {
+	entries := make([]historyEntry, 0)
+	f, err := os.Open(historyPath(tgkDir))
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return entries, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (e historyEntry) String() string {
+	s := fmt.Sprintf("%s  %-12s %s -> %s  %6.1fs  %s",
+		e.Time.Local().Format("2006-01-02 15:04:05"), e.User, e.From, e.To, float64(e.DurationMs)/1000, e.Result)
+	if e.Error != "" {
+		s += ": " + e.Error
+	}
+	return s
+}
+
+// lastSwap returns the most recent attempt that actually moved folders.
+func lastSwap(entries []historyEntry) (historyEntry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Result != RESULT_FAILURE {
+			return entries[i], true
+		}
+	}
+	return historyEntry{}, false
+}
+
+// UndoLastSwap swaps back to the client that was active before the last recorded swap. The outgoing client is named
+// after what the history says was swapped in, OldDirectory may have been changed since.
+func UndoLastSwap() error {
+	set := GetCompleteSettings(SETTINGS_FILE_NAME)
+	entries, err := readHistory(set.Defaults.Tgkdir)
+	if err != nil {
+		return err
+	}
+	last, ok := lastSwap(entries)
+	if !ok {
+		return errors.New("Nothing to undo, no swap has been recorded yet.")
+	}
+	set.ActiveSettings.OldDirectory = last.To
+	return swapDirectories(set, last.From, SETTINGS_FILE_NAME)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_history(t *testing.T) {
+	tgkDir := t.TempDir()
+	entries, err := readHistory(tgkDir)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Empty history read as %v, %v", entries, err)
+	}
+	if _, ok := lastSwap(entries); ok {
+		t.Errorf("Found a last swap in an empty history")
+	}
+	start := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	appendHistory(tgkDir, historyEntry{Time: start, User: "anna", From: "A", To: "B", DurationMs: 1200, Result: RESULT_SUCCESS})
+	appendHistory(tgkDir, historyEntry{Time: start.Add(time.Hour), User: "anna", From: "B", To: "C", Result: RESULT_PARTIAL, Error: "excel"})
+	// a line cut off by a crash must not hide the rest of the history
+	f, err := os.OpenFile(historyPath(tgkDir), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time": "2026-10-19T11:`)
+	f.WriteString("\n")
+	f.Close()
+	appendHistory(tgkDir, historyEntry{Time: start.Add(2 * time.Hour), User: "anna", From: "C", To: "D", Result: RESULT_FAILURE, Error: "locked"})
+
+	entries, err = readHistory(tgkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Read %d entries, want 3: %v", len(entries), entries)
+	}
+	if !entries[0].Time.Equal(start) || entries[0].From != "A" || entries[0].DurationMs != 1200 {
+		t.Errorf("First entry did not survive the round trip: %+v", entries[0])
+	}
+	// the failed attempt to D moved nothing, undo goes back from C to B
+	last, ok := lastSwap(entries)
+	if !ok || last.From != "B" || last.To != "C" {
+		t.Errorf("Last swap is %+v, want B -> C", last)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Hooks are shell commands from the settings that run around a swap. They get the details of the swap through
+// FASTSWAPPER_* environment variables, and their output ends up in the log (see logging.go).
+
+const (
+	HOOK_PRE_SWAP   = "preswap"
+	HOOK_POST_SWAP  = "postswap"
+	HOOK_ON_FAILURE = "onfailure"
+	// not a hook but how long each of them may run, set with -hk timeout <duration>
+	HOOK_TIMEOUT         = "timeout"
+	HOOK_DEFAULT_TIMEOUT = 60 * time.Second
+)
+
+// maps the hook names used on the command line to the fields of Hooks
+var hookFields = map[string]string{
+	HOOK_PRE_SWAP:   "PreSwap",
+	HOOK_POST_SWAP:  "PostSwap",
+	HOOK_ON_FAILURE: "OnFailure",
+	HOOK_TIMEOUT:    "Timeout",
+}
+
+// hookTimeout returns how long a single hook may run. A missing or unreadable setting means HOOK_DEFAULT_TIMEOUT.
+func hookTimeout(hooks Hooks) time.Duration {
+	timeout, err := time.ParseDuration(hooks.Timeout)
+	if err != nil || timeout <= 0 {
+		return HOOK_DEFAULT_TIMEOUT
+	}
+	return timeout
+}
+
+// hookEnv describes a swap from the active client to newDirName for the hook scripts.
+func hookEnv(set Settings, newDirName string) []string {
+	tgkDir := set.Defaults.Tgkdir
+	return []string{
+		"FASTSWAPPER_OLD_CLIENT=" + set.ActiveSettings.OldDirectory,
+		"FASTSWAPPER_NEW_CLIENT=" + newDirName,
+		"FASTSWAPPER_TGKDIR=" + tgkDir,
+		"FASTSWAPPER_TGKFOLDER=" + set.Defaults.Tgkfolder,
+		"FASTSWAPPER_ADDIN_PATH=" + filepath.Join(tgkDir, set.Defaults.Tgkfolder),
+		"FASTSWAPPER_OLD_PATH=" + filepath.Join(tgkDir, set.ActiveSettings.OldDirectory),
+		"FASTSWAPPER_NEW_PATH=" + filepath.Join(tgkDir, newDirName),
+	}
+}
+
+// runHook runs command through the system shell with env added to the environment and logs its output.
+// An empty command is a hook that is not configured. A non-zero exit, or running longer than timeout, is returned as
+// error.
+func runHook(name string, command string, env []string, timeout time.Duration) error {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	// the shell is killed on timeout, a child it started may still hold the output pipe open
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(), append(env, "FASTSWAPPER_HOOK="+name)...)
+	slog.Debug("running hook", "hook", name, "command", command)
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimRight(strings.ReplaceAll(string(out), "\r\n", "\n"), "\n")
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		slog.Error("hook timed out", "hook", name, "command", command, "timeout", timeout, "output", output)
+		return fmt.Errorf("%s hook did not finish within %s.", name, timeout)
+	}
+	if err != nil {
+		slog.Error("hook failed", "hook", name, "command", command, "output", output, "error", err)
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	slog.Info("hook finished", "hook", name, "command", command, "output", output)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func Test_runHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands below need a POSIX shell")
+	}
+	if err := runHook(HOOK_PRE_SWAP, "", nil, time.Second); err != nil {
+		t.Errorf("Hook that is not configured failed: %s", err)
+	}
+	if err := runHook(HOOK_PRE_SWAP, `test "$FASTSWAPPER_NEW_CLIENT" = "Kunde B"`, []string{"FASTSWAPPER_NEW_CLIENT=Kunde B"}, time.Second); err != nil {
+		t.Errorf("Hook did not see its environment: %s", err)
+	}
+	if err := runHook(HOOK_PRE_SWAP, "exit 3", nil, time.Second); err == nil {
+		t.Errorf("Non-zero exit was not a failure")
+	}
+	start := time.Now()
+	if err := runHook(HOOK_PRE_SWAP, "sleep 10", nil, 100*time.Millisecond); err == nil {
+		t.Errorf("Hook running into the timeout was not a failure")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Hook was not stopped at the timeout, took %s", elapsed)
+	}
+}
+
+func Test_hookTimeout(t *testing.T) {
+	for timeout, want := range map[string]time.Duration{
+		"":      HOOK_DEFAULT_TIMEOUT,
+		"2m":    2 * time.Minute,
+		"90s":   90 * time.Second,
+		"soon":  HOOK_DEFAULT_TIMEOUT,
+		"-5s":   HOOK_DEFAULT_TIMEOUT,
+		"0":     HOOK_DEFAULT_TIMEOUT,
+		"500ms": 500 * time.Millisecond,
+	} {
+		if got := hookTimeout(Hooks{Timeout: timeout}); got != want {
+			t.Errorf("hookTimeout(%q) = %s, want %s", timeout, got, want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The keys of the TUI are looked up in a keymap instead of being hard-coded in Update, so they can be changed in the
+// tui section of the settings, p.e. to move the swap off u:
+//
+//	"keys": {"list": {"swap": ["s"], "sort": ["o"]}, "pager": {"back": ["esc", "backspace"]}}
+//
+// Keys are named the way bubbletea reports them: a character, or names like pgup, enter, ctrl+a, alt+x, and space for
+// the space bar. An override replaces all keys of an action. The filter and prompt take text input and keep their fixed keys, ctrl+c
+// always quits so a broken keymap cannot lock anyone in.
+
+const (
+	KEYMAP_MODE_LIST  = "list"
+	KEYMAP_MODE_PAGER = "pager"
+
+	ACTION_QUIT        = "quit"
+	ACTION_HELP        = "help"
+	ACTION_UP          = "up"
+	ACTION_DOWN        = "down"
+	ACTION_PAGE_UP     = "pageup"
+	ACTION_PAGE_DOWN   = "pagedown"
+	ACTION_HOME        = "home"
+	ACTION_END         = "end"
+	ACTION_SELECT      = "select"
+	ACTION_SWAP        = "swap"
+	ACTION_SWAP_BACK   = "swapback"
+	ACTION_COLORS      = "colors"
+	ACTION_COLORS_BACK = "colorsback"
+	ACTION_BOX         = "box"
+	ACTION_BOX_BACK    = "boxback"
+	ACTION_THEMES      = "themes"
+	ACTION_DIFF        = "diff"
+	ACTION_HISTORY     = "history"
+	ACTION_LOG         = "log"
+	ACTION_STATUS      = "status"
+	ACTION_RENAME      = "rename"
+	ACTION_DUPLICATE   = "duplicate"
+	ACTION_DELETE      = "delete"
+	ACTION_TRASH       = "trash"
+	ACTION_FILTER      = "filter"
+	ACTION_SORT        = "sort"
+	ACTION_GROUP       = "group"
+	ACTION_BACK        = "back"
+	ACTION_RESTORE     = "restore"
+	ACTION_UNDO        = "undo"
+	ACTION_APPLY       = "apply"
+
+	// bubbletea reports the space bar as " ", which cannot be told apart from nothing in a settings file
+	SPACE_KEY_NAME = "space"
+)
+
+// keys that work in every mode and cannot be bound
+var reservedKeys = []string{"ctrl+c"}
+
+// the names bubbletea gives the keys that are not characters, p.e. "pgup" or "ctrl+a", as reported by KeyMsg.String()
+var namedKeys = func() map[string]bool {
+	names := make(map[string]bool)
+	for k := tea.KeyF20; k <= tea.KeyCtrlQuestionMark; k++ {
+		if name := k.String(); name != "" && k != tea.KeyRunes {
+			names[name] = true
+		}
+	}
+	return names
+}()
+
+// validKey tells if key is something bubbletea reports for a key press: a single character or the name of a key,
+// either optionally held with alt. A binding to anything else, p.e. "Swap" or "pageup", could never be pressed.
+func validKey(key string) bool {
+	key = strings.TrimPrefix(key, "alt+")
+	if namedKeys[key] {
+		return true
+	}
+	r, size := utf8.DecodeRuneInString(key)
+	return size == len(key) && r != utf8.RuneError && unicode.IsPrint(r) && !unicode.IsSpace(r)
+}
+
+type keyBinding struct {
+	Action string
+	Keys   []string
+	Help   string
+	// shown in the footer; the help overlay shows every binding
+	Footer bool
+	// the pager this binding is offered in, empty for all of them
+	Pager string
+}
+
+// the default bindings by mode, in the order the footer and help overlay show them
+var defaultBindings = map[string][]keyBinding{
+	KEYMAP_MODE_LIST: {
+		{Action: ACTION_QUIT, Keys: []string{"q"}, Help: "quit", Footer: true},
+		{Action: ACTION_HELP, Keys: []string{"?"}, Help: "help", Footer: true},
+		{Action: ACTION_UP, Keys: []string{"up", "k"}, Help: "move up"},
+		{Action: ACTION_DOWN, Keys: []string{"down", "j"}, Help: "move down"},
+		{Action: ACTION_PAGE_UP, Keys: []string{"pgup"}, Help: "page up"},
+		{Action: ACTION_PAGE_DOWN, Keys: []string{"pgdown"}, Help: "page down"},
+		{Action: ACTION_HOME, Keys: []string{"home"}, Help: "first entry"},
+		{Action: ACTION_END, Keys: []string{"end"}, Help: "last entry"},
+		{Action: ACTION_SELECT, Keys: []string{"enter", SPACE_KEY_NAME}, Help: "select"},
+		{Action: ACTION_SWAP, Keys: []string{"u"}, Help: "swap", Footer: true},
+		{Action: ACTION_SWAP_BACK, Keys: []string{"t"}, Help: "swap back", Footer: true},
+		{Action: ACTION_DIFF, Keys: []string{"d"}, Help: "diff", Footer: true},
+		{Action: ACTION_HISTORY, Keys: []string{"h"}, Help: "history", Footer: true},
+		{Action: ACTION_LOG, Keys: []string{"l"}, Help: "log", Footer: true},
+		{Action: ACTION_STATUS, Keys: []string{"i"}, Help: "status", Footer: true},
+		{Action: ACTION_RENAME, Keys: []string{"r"}, Help: "rename", Footer: true},
+		{Action: ACTION_DUPLICATE, Keys: []string{"y"}, Help: "duplicate", Footer: true},
+		{Action: ACTION_DELETE, Keys: []string{"x"}, Help: "delete", Footer: true},
+		{Action: ACTION_TRASH, Keys: []string{"X"}, Help: "trash", Footer: true},
+		{Action: ACTION_FILTER, Keys: []string{"/"}, Help: "filter", Footer: true},
+		{Action: ACTION_SORT, Keys: []string{"s"}, Help: "sort", Footer: true},
+		{Action: ACTION_GROUP, Keys: []string{"g"}, Help: "group", Footer: true},
+		{Action: ACTION_COLORS, Keys: []string{"c"}, Help: "change colors", Footer: true},
+		{Action: ACTION_BOX, Keys: []string{"b"}, Help: "change box", Footer: true},
+		{Action: ACTION_COLORS_BACK, Keys: []string{"C"}, Help: "previous colors"},
+		{Action: ACTION_BOX_BACK, Keys: []string{"B"}, Help: "previous box"},
+		{Action: ACTION_THEMES, Keys: []string{"T"}, Help: "themes", Footer: true},
+	},
+	KEYMAP_MODE_PAGER: {
+		{Action: ACTION_UP, Keys: []string{"up", "k"}, Help: "scroll up", Footer: true},
+		{Action: ACTION_DOWN, Keys: []string{"down", "j"}, Help: "scroll down", Footer: true},
+		{Action: ACTION_UNDO, Keys: []string{"u"}, Help: "undo last swap", Footer: true, Pager: "history"},
+		{Action: ACTION_RESTORE, Keys: []string{"r"}, Help: "restore top entry", Footer: true, Pager: "trash"},
+		{Action: ACTION_APPLY, Keys: []string{"enter"}, Help: "use top theme", Footer: true, Pager: "themes"},
+		{Action: ACTION_HELP, Keys: []string{"?"}, Help: "help", Footer: true},
+		{Action: ACTION_BACK, Keys: []string{"esc", "q"}, Help: "back", Footer: true},
+	},
+}
+
+// in the order they are checked for conflicts
+var keymapModes = []string{KEYMAP_MODE_LIST, KEYMAP_MODE_PAGER}
+
+type keymap struct {
+	// by mode, in display order
+	bindings map[string][]keyBinding
+	// key to action by mode
+	actions map[string]map[string]string
+}
+
+// loadKeymap applies overrides, by mode and action, to the default bindings. If they are invalid or conflict the
+// error says why and the default keymap is returned, so the TUI stays usable.
+func loadKeymap(overrides map[string]map[string][]string) (keymap, error) {
+	km, err := buildKeymap(overrides)
+	if err != nil {
+		km, _ = buildKeymap(nil)
+	}
+	return km, err
+}
+
+func buildKeymap(overrides map[string]map[string][]string) (keymap, error) {
+	km := keymap{bindings: make(map[string][]keyBinding), actions: make(map[string]map[string]string)}
+	for mode, modeOverrides := range overrides {
+		if _, ok := defaultBindings[mode]; !ok {
+			return km, fmt.Errorf("unknown key binding mode %q, must be %s or %s", mode, KEYMAP_MODE_LIST, KEYMAP_MODE_PAGER)
+		}
+		for action := range modeOverrides {
+			if !hasAction(defaultBindings[mode], action) {
+				return km, fmt.Errorf("unknown action %q in key bindings for %s, must be one of %s", action, mode, strings.Join(actionNames(mode), ", "))
+			}
+		}
+	}
+	for _, mode := range keymapModes {
+		km.actions[mode] = make(map[string]string)
+		for _, b := range defaultBindings[mode] {
+			if keys, ok := overrides[mode][b.Action]; ok {
+				b.Keys = keys
+			}
+			if len(b.Keys) == 0 {
+				return km, fmt.Errorf("action %s in %s has no key", b.Action, mode)
+			}
+			for _, key := range b.Keys {
+				lookup := key
+				if key == SPACE_KEY_NAME {
+					lookup = " "
+				}
+				if !validKey(lookup) {
+					return km, fmt.Errorf("key %q of %s in %s is not a key name, use a single character or names like pgup, enter or ctrl+a", key, b.Action, mode)
+				}
+				for _, reserved := range reservedKeys {
+					if lookup == reserved {
+						return km, fmt.Errorf("%s cannot be bound, it always quits", reserved)
+					}
+				}
+				if other, taken := km.actions[mode][lookup]; taken {
+					return km, fmt.Errorf("key %s is bound to both %s and %s in %s", key, other, b.Action, mode)
+				}
+				km.actions[mode][lookup] = b.Action
+			}
+			km.bindings[mode] = append(km.bindings[mode], b)
+		}
+	}
+	return km, nil
+}
+
+func hasAction(bindings []keyBinding, action string) bool {
+	for _, b := range bindings {
+		if b.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// action returns the action key is bound to in mode, empty if none.
+func (km keymap) action(mode string, key string) string {
+	return km.actions[mode][key]
+}
+
+// footer returns the footer items of mode, pager narrows the pager bindings down to the ones offered in that pager.
+func (km keymap) footer(mode string, pager string) []string {
+	items := make([]string, 0)
+	for _, b := range km.bindings[mode] {
+		if b.Footer && (b.Pager == "" || b.Pager == pager) {
+			items = append(items, b.String())
+		}
+	}
+	return items
+}
+
+// help returns the lines of the help overlay for mode: every binding, pager specific ones marked as such.
+func (km keymap) help(mode string) []string {
+	bindings := km.bindings[mode]
+	width := len(strings.Join(reservedKeys, "/"))
+	for _, b := range bindings {
+		width = max(width, len(b.keysString()))
+	}
+	lines := make([]string, 0, len(bindings)+1)
+	for _, b := range bindings {
+		line := fmt.Sprintf("%-*s  %s", width, b.keysString(), b.Help)
+		if b.Pager != "" {
+			line += " (" + b.Pager + ")"
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, fmt.Sprintf("%-*s  %s", width, strings.Join(reservedKeys, "/"), "quit from anywhere"))
+}
+
+func (b keyBinding) keysString() string {
+	return strings.Join(b.Keys, "/")
+}
+
+func (b keyBinding) String() string {
+	return b.keysString() + ": " + b.Help
+}
+
+// actionNames returns the actions that can be bound in mode, sorted.
+func actionNames(mode string) []string {
+	names := make([]string, 0, len(defaultBindings[mode]))
+	for _, b := range defaultBindings[mode] {
+		names = append(names, b.Action)
+	}
+	sort.Strings(names)
+	return names
+}
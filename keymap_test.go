@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func Test_buildKeymap(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]map[string][]string
+		// part of the error, empty if the keymap is valid
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"moved swap", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {"w"}}}, ""},
+		{"named keys", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {"ctrl+s", "f5", "alt+w"}}}, ""},
+		{"space alias", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SELECT: {SPACE_KEY_NAME}}}, ""},
+		{"same key in another mode", map[string]map[string][]string{KEYMAP_MODE_PAGER: {ACTION_BACK: {"u"}, ACTION_UNDO: {"z"}}}, ""},
+		{"conflict", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {"d"}}}, "bound to both"},
+		{"conflict with the space alias", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {" "}}}, "bound to both"},
+		{"reserved", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_QUIT: {"ctrl+c"}}}, "cannot be bound"},
+		{"unknown mode", map[string]map[string][]string{"prompt": {ACTION_BACK: {"esc"}}}, "unknown key binding mode"},
+		{"unknown action", map[string]map[string][]string{KEYMAP_MODE_LIST: {"fly": {"f"}}}, "unknown action"},
+		{"action of another mode", map[string]map[string][]string{KEYMAP_MODE_PAGER: {ACTION_SWAP: {"w"}}}, "unknown action"},
+		{"no key", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {}}}, "has no key"},
+		{"word", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {"Swap"}}}, "not a key name"},
+		{"name bubbletea does not use", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_PAGE_UP: {"pageup"}}}, "not a key name"},
+		{"empty", map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {""}}}, "not a key name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildKeymap(tt.overrides)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Valid keymap was rejected: %s", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_loadKeymap(t *testing.T) {
+	km, err := loadKeymap(map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SWAP: {"d"}}})
+	if err == nil {
+		t.Fatal("Conflicting keymap was accepted")
+	}
+	// the defaults stay usable
+	if got := km.action(KEYMAP_MODE_LIST, "u"); got != ACTION_SWAP {
+		t.Errorf("u is bound to %q after an invalid override, want the default %s", got, ACTION_SWAP)
+	}
+
+	km, err = loadKeymap(map[string]map[string][]string{KEYMAP_MODE_LIST: {ACTION_SELECT: {SPACE_KEY_NAME}, ACTION_SWAP: {"ctrl+s"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// looked up the way Update does it, by what bubbletea reports for the key press
+	for _, tt := range []struct {
+		msg  tea.KeyMsg
+		want string
+	}{
+		{tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}, ACTION_SELECT},
+		{tea.KeyMsg{Type: tea.KeyEnter}, ""},
+		{tea.KeyMsg{Type: tea.KeyCtrlS}, ACTION_SWAP},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}}, ""},
+	} {
+		if got := km.action(KEYMAP_MODE_LIST, tt.msg.String()); got != tt.want {
+			t.Errorf("%q is bound to %q, want %q", tt.msg.String(), got, tt.want)
+		}
+	}
+}
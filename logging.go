@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// Everything worth diagnosing goes through log/slog into a rotating file of JSON lines next to the settings file.
+// Info and above are always written, --verbose adds debug records and on the CLI mirrors everything to stderr.
+
+const (
+	LOG_FILE_NAME  string = "fastSwapper.log"
+	VERBOSE_FLAG          = "--verbose"
+	LOG_MAX_BYTES         = 1 << 20
+	LOG_BACKUPS           = 3
+	LOG_TAIL_LINES        = 200
+)
+
+func logFilePath() string {
+	return filepath.Join(filepath.Dir(settingsFilePath()), LOG_FILE_NAME)
+}
+
+// initLogger makes the log file the destination of the default slog logger. toStderr is only used on the CLI,
+// in the TUI anything written to stderr would end up in the middle of the screen.
+func initLogger(verbose bool, toStderr bool) {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	options := &slog.HandlerOptions{Level: level}
+	handlers := teeHandler{slog.NewJSONHandler(utils.NewRotatingFile(logFilePath(), LOG_MAX_BYTES, LOG_BACKUPS), options)}
+	if verbose && toStderr {
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, options))
+	}
+	slog.SetDefault(slog.New(handlers))
+}
+
+// fatal logs err and tells it on stderr before exiting. Not through log.Fatal: once initLogger ran, the log package
+// writes into the log file only, and at info level.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	fmt.Fprintf(os.Stderr, "%s: %s\n", msg, err)
+	os.Exit(1)
+}
+
+// teeHandler hands every record to all of its handlers.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			if e := h.Handle(ctx, r.Clone()); e != nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	result := make(teeHandler, len(t))
+	for i, h := range t {
+		result[i] = h.WithAttrs(attrs)
+	}
+	return result
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	result := make(teeHandler, len(t))
+	for i, h := range t {
+		result[i] = h.WithGroup(name)
+	}
+	return result
+}
+
+// logTail returns the last n records of the log file formatted for humans, oldest first.
+func logTail(n int) ([]string, error) {
+	f, err := os.Open(logFilePath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	// hook output can make single records long
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, formatLogRecord(scanner.Text()))
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// formatLogRecord turns one JSON record into "time LEVEL msg key=value ..."; lines that are not JSON stay as they are.
+func formatLogRecord(line string) string {
+	record := make(map[string]interface{})
+	if json.Unmarshal([]byte(line), &record) != nil {
+		return line
+	}
+	s := fmt.Sprintf("%v %-5v %v", record[slog.TimeKey], record[slog.LevelKey], record[slog.MessageKey])
+	if t, err := time.Parse(time.RFC3339Nano, fmt.Sprint(record[slog.TimeKey])); err == nil {
+		s = fmt.Sprintf("%s %-5v %v", t.Local().Format("2006-01-02 15:04:05"), record[slog.LevelKey], record[slog.MessageKey])
+	}
+	delete(record, slog.TimeKey)
+	delete(record, slog.LevelKey)
+	delete(record, slog.MessageKey)
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += "  " + k + "=" + strings.ReplaceAll(fmt.Sprint(record[k]), "\n", "\\n")
+	}
+	return s
+}
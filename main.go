@@ -1,10 +1,32 @@
 package main
 
-//"os"
+import (
+	"os"
+
+	"fastSwapper/utils"
+)
 
 func main() {
+	// --verbose and --plain can be given anywhere and are not passed on
+	verbose := utils.ContainsString(os.Args, VERBOSE_FLAG)
+	plain := wantsPlainMode(os.Args)
+	args := utils.Remove(utils.Remove(os.Args, VERBOSE_FLAG), PLAIN_FLAG)
+	// any argument means the swapper is used as CLI
+	if len(args) > 1 {
+		initLogger(verbose, true)
+		// RunSwapper already reported the error in the requested output format
+		err := RunSwapper(args)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	initLogger(verbose, false)
 	// Initialize Settings
 	InitSettingsJSON()
 	// start the TUI
-	runTui()
+	err := runTui(plain)
+	if err != nil {
+		fatal("could not run the TUI", err)
+	}
 }
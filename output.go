@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// With --output json every command prints exactly one JSON object to stdout:
+//
+//	{"version": 1, "command": "list", "ok": true, "result": {...}}
+//	{"version": 1, "command": "swap", "ok": false, "error": {"code": "swap_failed", "message": "..."}}
+//
+// Scripts rely on this, so fields and error codes are only ever added, never renamed or removed. Anything that changes
+// the meaning of an existing field needs a new OUTPUT_SCHEMA_VERSION.
+
+const (
+	OUTPUT_FLAG           = "--output"
+	OUTPUT_TEXT           = "text"
+	OUTPUT_JSON           = "json"
+	OUTPUT_SCHEMA_VERSION = 1
+)
+
+// error codes of the JSON output
+const (
+	ERR_USAGE               = "usage"
+	ERR_UNKNOWN_FLAG        = "unknown_flag"
+	ERR_MISSING_ARGUMENT    = "missing_argument"
+	ERR_INVALID_ARGUMENT    = "invalid_argument"
+	ERR_INVALID_NAME        = "invalid_name"
+	ERR_NOT_FOUND           = "not_found"
+	ERR_VERIFICATION_FAILED = "verification_failed"
+	ERR_SWAP_FAILED         = "swap_failed"
+	ERR_NAME_COLLISION      = "name_collision"
+	ERR_INVALID_SETTINGS    = "invalid_settings"
+	// anything that went wrong while doing the work, p.e. a file that could not be written
+	ERR_OPERATION_FAILED = "operation_failed"
+)
+
+// set once from the command line in RunSwapper
+var outputFormat = OUTPUT_TEXT
+
+type cliError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// what the command found before it failed, p.e. the report of a failed verification
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *cliError) Error() string {
+	return e.Message
+}
+
+func newCLIError(code string, message string) *cliError {
+	return &cliError{Code: code, Message: message}
+}
+
+// asCLIError gives every error a code, errors from deeper down get code.
+func asCLIError(err error, code string) *cliError {
+	var ce *cliError
+	if errors.As(err, &ce) {
+		return ce
+	}
+	return newCLIError(code, err.Error())
+}
+
+type outputEnvelope struct {
+	Version int         `json:"version"`
+	Command string      `json:"command"`
+	OK      bool        `json:"ok"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *cliError   `json:"error,omitempty"`
+}
+
+// extractOutputFormat removes --output <format> (or --output=<format>) from args and returns the format.
+func extractOutputFormat(args []string) ([]string, string, error) {
+	rest, format, err := extractOption(args, OUTPUT_FLAG)
+	if err != nil {
+		return args, OUTPUT_TEXT, newCLIError(ERR_MISSING_ARGUMENT, "No output format provided. Use --output <text|json>.")
+	}
+	if format == "" {
+		format = OUTPUT_TEXT
+	}
+	if format != OUTPUT_TEXT && format != OUTPUT_JSON {
+		return args, OUTPUT_TEXT, newCLIError(ERR_INVALID_ARGUMENT, "Output format must be either text or json.")
+	}
+	return rest, format, nil
+}
+
+// commandName is how a flag is called in the JSON output; commands are called like themselves.
+func commandName(flag string) string {
+	switch flag {
+	case SWAP_FLAG:
+		return "swap"
+	case HELP_FLAG:
+		return "help"
+	}
+	return flag
+}
+
+// emitResult prints the result of command, as text or wrapped into the JSON envelope.
+func emitResult(command string, result interface{}, text string) {
+	if outputFormat != OUTPUT_JSON {
+		fmt.Print(text)
+		return
+	}
+	printEnvelope(outputEnvelope{Version: OUTPUT_SCHEMA_VERSION, Command: command, OK: true, Result: result})
+}
+
+// emitError prints err for command; in JSON mode as error object with a code.
+func emitError(command string, err error) {
+	if outputFormat != OUTPUT_JSON {
+		fmt.Println(err)
+		return
+	}
+	printEnvelope(outputEnvelope{Version: OUTPUT_SCHEMA_VERSION, Command: command, OK: false, Error: asCLIError(err, ERR_OPERATION_FAILED)})
+}
+
+func printEnvelope(e outputEnvelope) {
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		// only happens for results that cannot be serialised, which is a bug
+		b, _ = json.Marshal(outputEnvelope{Version: OUTPUT_SCHEMA_VERSION, Command: e.Command, Error: newCLIError(ERR_OPERATION_FAILED, err.Error())})
+	}
+	fmt.Println(string(b))
+}
+
+// results of the commands with JSON output
+
+type listResult struct {
+	Active  string   `json:"active"`
+	Clients []string `json:"clients"`
+}
+
+func (r listResult) String() string {
+	s := fmt.Sprintf("* %s (active)\n", r.Active)
+	for _, c := range r.Clients {
+		s += "  " + c + "\n"
+	}
+	return s
+}
+
+type swapResult struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	DurationMs int64  `json:"durationms"`
+}
+
+// emitSwapResult reports a finished swap of command that started at start. The outgoing client may have been saved
+// under another name than the settings said before, see resolveCollision, so both names are read back afterwards.
+func emitSwapResult(command string, start time.Time) {
+	active := getActiveSettings(SETTINGS_FILE_NAME)
+	result := swapResult{From: active.PreviousDirectory, To: active.OldDirectory, DurationMs: time.Since(start).Milliseconds()}
+	emitResult(command, result, fmt.Sprintf("Swapped in %s, the previous client was saved as %s.\n", result.To, result.From))
+}
+
+type helpEntry struct {
+	Flag        string `json:"flag"`
+	Description string `json:"description"`
+}
+
+type helpResult struct {
+	Flags []helpEntry `json:"flags"`
+}
+
+func newHelpResult(help helpInformation, only string) helpResult {
+	r := helpResult{Flags: make([]helpEntry, 0)}
+	for k, v := range help.availableFlagsWithDesc {
+		if only == "" || only == k {
+			r.Flags = append(r.Flags, helpEntry{Flag: k, Description: v})
+		}
+	}
+	sort.Slice(r.Flags, func(i, j int) bool { return r.Flags[i].Flag < r.Flags[j].Flag })
+	return r
+}
+
+func (r helpResult) String() string {
+	s := ""
+	for _, e := range r.Flags {
+		s += fmt.Sprintf("flag: %s\t%s\n", e.Flag, e.Description)
+	}
+	return s
+}
+
+// result of the flags that change a setting
+type settingResult struct {
+	Setting string      `json:"setting"`
+	Value   interface{} `json:"value"`
+}
+
+type storeResult struct {
+	Stored []string `json:"stored"`
+}
+
+func (r storeResult) String() string {
+	s := ""
+	for _, c := range r.Stored {
+		s += c + " moved into the store.\n"
+	}
+	return s
+}
+
+type gcResult struct {
+	Removed int   `json:"removed"`
+	Freed   int64 `json:"freed"`
+}
+
+type recordResult struct {
+	Client string `json:"client"`
+	Files  int    `json:"files"`
+}
+
+type historyResult struct {
+	Entries []historyEntry `json:"entries"`
+}
+
+func (r historyResult) String() string {
+	s := ""
+	for _, e := range r.Entries {
+		s += e.String() + "\n"
+	}
+	return s
+}
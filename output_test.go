@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what f printed to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	f()
+	w.Close()
+	return string(<-done)
+}
+
+// useSettings runs the rest of the test in an empty working directory with set as its settings.json.
+func useSettings(t *testing.T, set Settings) {
+	t.Helper()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+	})
+	updateSettingsJson(SETTINGS_FILE_NAME, set)
+}
+
+// Every command, failing or not, has to print exactly one JSON object with --output json.
+func Test_jsonOutput(t *testing.T) {
+	tgkDir := t.TempDir()
+	useSettings(t, Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	})
+	t.Cleanup(func() {
+		outputFormat = OUTPUT_TEXT
+	})
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{"app.config": "a"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "b"})
+
+	// the text output of a setting stays as quiet as it was
+	out := captureStdout(t, func() {
+		RunSwapper([]string{"fastSwapper", SET_COLLISION_STRATEGY_FLAG, COLLISION_SUFFIX})
+	})
+	if out != "" {
+		t.Errorf("Setting the collision strategy printed %q", out)
+	}
+	if !strings.Contains(captureStdout(t, func() { RunSwapper([]string{"fastSwapper", LIST_COMMAND}) }), "(active)") {
+		t.Errorf("Text output of list lost its format")
+	}
+
+	commands := [][]string{
+		{LIST_COMMAND},
+		{STATUS_COMMAND},
+		{CONFIG_COMMAND},
+		{HELP_FLAG},
+		{HELP_FLAG, SWAP_FLAG},
+		{"-nope"},
+		{SET_OLDDIR_NAME_FLAG, "Kunde", "A"},
+		{SET_OLDDIR_NAME_FLAG},
+		{SET_TGK_FOLDER_FLAG, "Addin"},
+		{SET_DEFAULT_PATH_FLAG, tgkDir},
+		{SET_COLLISION_STRATEGY_FLAG, COLLISION_ABORT},
+		{SET_VERIFY_BEFORE_SWAP_FLAG, "off"},
+		{SET_HOOK_FLAG, HOOK_TIMEOUT, "30s"},
+		{SET_HOOK_FLAG, HOOK_TIMEOUT, "soon"},
+		{SET_STORAGE_MODE_FLAG, STORAGE_MODE_FOLDERS},
+		{INFO_COMMAND, "Kunde B"},
+		{INFO_COMMAND, "Kunde B", "customer", "ACME", "AG"},
+		{RECORD_COMMAND, "Kunde B"},
+		{VERIFY_COMMAND, "Kunde B"},
+		{DIFF_COMMAND, "Kunde A", "Kunde B"},
+		{DIFF_COMMAND, "Kunde A"},
+		{DUPLICATE_COMMAND, "Kunde B", "Kunde C"},
+		{RENAME_COMMAND, "Kunde C", "Kunde D"},
+		{DELETE_COMMAND, "Kunde D"},
+		{TRASH_COMMAND},
+		{RESTORE_COMMAND, "Kunde D"},
+		{SWAP_FLAG, "Nobody"},
+		{SWAP_FLAG, "Kunde B"},
+		{HISTORY_COMMAND},
+		{HISTORY_COMMAND, "0"},
+		{BACK_COMMAND},
+		{UNDO_COMMAND},
+		{BACK_COMMAND, "now"},
+		{STORE_COMMAND, "Kunde D"},
+		{GC_COMMAND},
+		{DOCTOR_COMMAND},
+		{SET_DEFAULT_WINPATH_FLAG},
+	}
+	run := func(args ...string) {
+		t.Helper()
+		out := captureStdout(t, func() {
+			RunSwapper(append([]string{"fastSwapper", OUTPUT_FLAG, OUTPUT_JSON}, args...))
+		})
+		dec := json.NewDecoder(strings.NewReader(out))
+		var envelope outputEnvelope
+		if err := dec.Decode(&envelope); err != nil {
+			t.Errorf("%v printed no JSON object: %s\n%s", args, err, out)
+			return
+		}
+		if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+			t.Errorf("%v printed more than one JSON object:\n%s", args, out)
+		}
+		if envelope.Version != OUTPUT_SCHEMA_VERSION || envelope.Command == "" {
+			t.Errorf("%v printed an incomplete envelope:\n%s", args, out)
+		}
+		if !envelope.OK && (envelope.Error == nil || envelope.Error.Code == "") {
+			t.Errorf("%v failed without an error code:\n%s", args, out)
+		}
+	}
+	for _, args := range commands {
+		run(args...)
+		if args[0] == VERIFY_COMMAND {
+			// and once more with a failed verification
+			writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "changed"})
+			run(args...)
+		}
+	}
+}
+
+// runJSON runs the swapper with --output json and returns the one envelope it printed.
+func runJSON(t *testing.T, args ...string) outputEnvelope {
+	t.Helper()
+	out := captureStdout(t, func() {
+		RunSwapper(append([]string{"fastSwapper", OUTPUT_FLAG, OUTPUT_JSON}, args...))
+	})
+	var envelope outputEnvelope
+	dec := json.NewDecoder(strings.NewReader(out))
+	if err := dec.Decode(&envelope); err != nil {
+		t.Fatalf("%v printed no JSON object: %s\n%s", args, err, out)
+	}
+	if err := dec.Decode(&json.RawMessage{}); err != io.EOF {
+		t.Errorf("%v printed more than one JSON object:\n%s", args, out)
+	}
+	return envelope
+}
+
+func Test_jsonOutput_brokenSettings(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+		outputFormat = OUTPUT_TEXT
+	})
+	check := func(wantCode string, args ...string) {
+		t.Helper()
+		envelope := runJSON(t, args...)
+		if envelope.OK || envelope.Error == nil || envelope.Error.Code != wantCode {
+			t.Errorf("%v printed %+v, want error code %s", args, envelope, wantCode)
+		}
+	}
+
+	for _, command := range []string{LIST_COMMAND, STATUS_COMMAND, CONFIG_COMMAND, HISTORY_COMMAND} {
+		check(ERR_NOT_FOUND, command)
+	}
+	check(ERR_NOT_FOUND, SWAP_FLAG, "Kunde B")
+	if envelope := runJSON(t, HELP_FLAG); !envelope.OK {
+		t.Errorf("Help needs no settings but failed: %+v", envelope.Error)
+	}
+
+	if err := os.WriteFile(SETTINGS_FILE_NAME, []byte(`{"Defaults": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check(ERR_INVALID_SETTINGS, LIST_COMMAND)
+
+	updateSettingsJson(SETTINGS_FILE_NAME, Settings{Defaults: Default{Tgkdir: filepath.Join(t.TempDir(), "gone"), Tgkfolder: "Addin"}})
+	check(ERR_NOT_FOUND, LIST_COMMAND)
+	check(ERR_NOT_FOUND, STORE_COMMAND)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+
+	"fastSwapper/tuiAssets"
+	"fastSwapper/utils"
+)
+
+// Plain mode is for consoles that cannot show colors or Unicode, p.e. old RDP sessions, and for screen readers: no
+// colors, ASCII borders and only ASCII markers. It is switched on by --plain, NO_COLOR (see no-color.org) or a dumb
+// terminal.
+
+const (
+	PLAIN_FLAG = "--plain"
+)
+
+// set by usePlainMode, the choices made in the TUI cannot bring the Unicode back
+var plainMode = false
+
+func wantsPlainMode(args []string) bool {
+	return utils.ContainsString(args, PLAIN_FLAG) || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+}
+
+// usePlainMode switches the TUI to plain mode for the rest of the run.
+func usePlainMode() {
+	plainMode = true
+	// lipgloss drops every color, including those of themes picked later
+	lipgloss.SetColorProfile(termenv.Ascii)
+	activeBox = tuiAssets.Ascii()
+	moreAboveSymbol = "^"
+	moreBelowSymbol = "v"
+	utils.Ellipsis = "..."
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// The TUI list can be sorted and grouped. Sorting by anything but the name needs facts about every client, which are
+// collected into clientStats once per refresh and only if the chosen mode needs them.
+
+const (
+	SORT_NAME      = "name"
+	SORT_LAST_USED = "lastused"
+	SORT_MODIFIED  = "modified"
+	SORT_SIZE      = "size"
+	SORT_VERSION   = "version"
+	GROUP_NONE     = ""
+	GROUP_CUSTOMER = "customer"
+	GROUP_VERSION  = "version"
+	// the group of clients without a customer or version
+	NO_GROUP_LABEL = "(none)"
+)
+
+var (
+	sortModes  = []string{SORT_NAME, SORT_LAST_USED, SORT_MODIFIED, SORT_SIZE, SORT_VERSION}
+	groupModes = []string{GROUP_NONE, GROUP_CUSTOMER, GROUP_VERSION}
+)
+
+type clientStats struct {
+	Size     int64
+	Files    int
+	Modified time.Time
+	// zero if the client never took part in a recorded swap
+	LastUsed time.Time
+}
+
+// lastUsedTimes returns for every client in the history when it was last swapped in or out.
+func lastUsedTimes(tgkDir string) map[string]time.Time {
+	result := make(map[string]time.Time)
+	entries, err := readHistory(tgkDir)
+	if err != nil {
+		return result
+	}
+	for _, e := range entries {
+		if e.Result == RESULT_FAILURE {
+			continue
+		}
+		for _, client := range []string{e.From, e.To} {
+			if e.Time.After(result[client]) {
+				result[client] = e.Time
+			}
+		}
+	}
+	return result
+}
+
+// collectClientStats adds up the files of client; clients that only live in the store are described by their manifest.
+func collectClientStats(set Settings, client string, lastUsed map[string]time.Time) clientStats {
+	stats := clientStats{LastUsed: lastUsed[client]}
+	tgkDir := set.Defaults.Tgkdir
+	folderPath := clientFolderPath(set, client)
+	if !utils.Exists(folderPath) {
+		m, err := readManifest(manifestPath(tgkDir, client))
+		if err != nil {
+			return stats
+		}
+		for _, f := range m.Files {
+			stats.Size += f.Size
+			stats.Files++
+		}
+		if info, err := os.Stat(manifestPath(tgkDir, client)); err == nil {
+			stats.Modified = info.ModTime()
+		}
+		return stats
+	}
+	filepath.WalkDir(folderPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || d.Name() == MARKER_FILE_NAME {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stats.Size += info.Size()
+		stats.Files++
+		if info.ModTime().After(stats.Modified) {
+			stats.Modified = info.ModTime()
+		}
+		return nil
+	})
+	return stats
+}
+
+// sortNeedsStats tells whether sorting by mode needs collectClientStats.
+func sortNeedsStats(mode string) bool {
+	return mode == SORT_LAST_USED || mode == SORT_MODIFIED || mode == SORT_SIZE
+}
+
+// groupOf returns the group client belongs to under mode.
+func groupOf(mode string, info clientInfo) string {
+	value := ""
+	switch mode {
+	case GROUP_CUSTOMER:
+		value = info.Customer
+	case GROUP_VERSION:
+		value = info.Version
+	}
+	if strings.TrimSpace(value) == "" {
+		return NO_GROUP_LABEL
+	}
+	return value
+}
+
+// sortClients orders clients by group, then by mode. Newest, biggest and most recently used come first, names are
+// sorted alphabetically.
+func sortClients(clients []string, mode string, group string, infos map[string]clientInfo, stats map[string]clientStats) []string {
+	sorted := append([]string(nil), clients...)
+	less := func(a string, b string) bool {
+		switch mode {
+		case SORT_LAST_USED:
+			if !stats[a].LastUsed.Equal(stats[b].LastUsed) {
+				return stats[a].LastUsed.After(stats[b].LastUsed)
+			}
+		case SORT_MODIFIED:
+			if !stats[a].Modified.Equal(stats[b].Modified) {
+				return stats[a].Modified.After(stats[b].Modified)
+			}
+		case SORT_SIZE:
+			if stats[a].Size != stats[b].Size {
+				return stats[a].Size > stats[b].Size
+			}
+		case SORT_VERSION:
+			if c := utils.CompareVersions(infos[a].Version, infos[b].Version); c != 0 {
+				return c > 0
+			}
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if group != GROUP_NONE {
+			ga, gb := groupOf(group, infos[a]), groupOf(group, infos[b])
+			if ga != gb {
+				// clients without a group go last
+				if ga == NO_GROUP_LABEL || gb == NO_GROUP_LABEL {
+					return gb == NO_GROUP_LABEL
+				}
+				if group == GROUP_VERSION {
+					return utils.CompareVersions(ga, gb) > 0
+				}
+				return strings.ToLower(ga) < strings.ToLower(gb)
+			}
+		}
+		return less(a, b)
+	})
+	return sorted
+}
+
+// nextMode returns the mode after current in modes, wrapping around.
+func nextMode(modes []string, current string) string {
+	for i, mode := range modes {
+		if mode == current {
+			return modes[(i+1)%len(modes)]
+		}
+	}
+	return modes[0]
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_sortClients(t *testing.T) {
+	now := time.Now()
+	clients := []string{"beta", "Alpha", "gamma", "delta"}
+	infos := map[string]clientInfo{
+		"Alpha": {Customer: "Zeta AG", Version: "5.10"},
+		"beta":  {Customer: "ACME", Version: "5.9"},
+		"gamma": {Customer: "ACME"},
+	}
+	stats := map[string]clientStats{
+		"Alpha": {Size: 10, Modified: now.Add(-time.Hour)},
+		"beta":  {Size: 30, Modified: now, LastUsed: now},
+		"gamma": {Size: 20, Modified: now.Add(-2 * time.Hour), LastUsed: now.Add(-time.Hour)},
+		"delta": {Size: 30},
+	}
+	tests := []struct {
+		mode  string
+		group string
+		want  []string
+	}{
+		{SORT_NAME, GROUP_NONE, []string{"Alpha", "beta", "delta", "gamma"}},
+		{SORT_SIZE, GROUP_NONE, []string{"beta", "delta", "gamma", "Alpha"}},
+		{SORT_MODIFIED, GROUP_NONE, []string{"beta", "Alpha", "gamma", "delta"}},
+		{SORT_LAST_USED, GROUP_NONE, []string{"beta", "gamma", "Alpha", "delta"}},
+		// 5.10 is newer than 5.9, clients without a version go last
+		{SORT_VERSION, GROUP_NONE, []string{"Alpha", "beta", "delta", "gamma"}},
+		{SORT_NAME, GROUP_CUSTOMER, []string{"beta", "gamma", "Alpha", "delta"}},
+		{SORT_SIZE, GROUP_VERSION, []string{"Alpha", "beta", "delta", "gamma"}},
+	}
+	for _, tt := range tests {
+		if got := sortClients(clients, tt.mode, tt.group, infos, stats); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Sorted by %q grouped by %q: got %v, want %v", tt.mode, tt.group, got, tt.want)
+		}
+	}
+	if !reflect.DeepEqual(clients, []string{"beta", "Alpha", "gamma", "delta"}) {
+		t.Errorf("Sorting changed its input to %v", clients)
+	}
+}
+
+func Test_collectClientStats_store(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{Defaults: Default{Tgkdir: tgkDir, Tgkfolder: "Addin", StorageMode: STORAGE_MODE_STORE}}
+	for rel, content := range map[string]string{"a.config": "123", "sub/b.config": "4567"} {
+		path := filepath.Join(tgkDir, "Kunde B", filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storeFolder(tgkDir, "Kunde B", filepath.Join(tgkDir, "Kunde B")); err != nil {
+		t.Fatal(err)
+	}
+	stats := collectClientStats(set, "Kunde B", nil)
+	if stats.Files != 2 || stats.Size != 7 || stats.Modified.IsZero() {
+		t.Errorf("Stored client has stats %+v, want 2 files of 7 bytes", stats)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fastSwapper/utils"
+)
+
+// Besides the settings, two files tell which client is where:
+//
+//	<Tgkfolder>/.fastSwapper-client   marker with the name of the client the folder belongs to, travels with the folder
+//	<Tgkdir>/.fastSwapper/journal.json written while a swap moves folders, left behind if it never finishes
+//
+// Both are bookkeeping of the swapper and not part of any client, see scanFolder.
+
+const (
+	MARKER_FILE_NAME = ".fastSwapper-client"
+)
+
+type swapJournal struct {
+	Started     time.Time `json:"started"`
+	User        string    `json:"user"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Tgkfolder   string    `json:"tgkfolder"`
+	StorageMode string    `json:"storagemode"`
+	// the last step that was started
+	Step string `json:"step"`
+}
+
+func journalPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "journal.json")
+}
+
+// writeJournal records that j.Step is about to start.
+func writeJournal(tgkDir string, j swapJournal) error {
+	err := os.MkdirAll(metaDirPath(tgkDir), 0755)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return err
+	}
+	slog.Debug("swap journal written", "step", j.Step)
+	return os.WriteFile(journalPath(tgkDir), b, 0644)
+}
+
+// readJournal returns the journal of an unfinished swap; ok is false if there is none.
+func readJournal(tgkDir string) (j swapJournal, ok bool, err error) {
+	b, err := os.ReadFile(journalPath(tgkDir))
+	if os.IsNotExist(err) {
+		return j, false, nil
+	}
+	if err != nil {
+		return j, false, err
+	}
+	err = json.Unmarshal(b, &j)
+	return j, err == nil, err
+}
+
+func clearJournal(tgkDir string) error {
+	err := os.Remove(journalPath(tgkDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// readMarker returns the client name stored in the marker of folderPath, empty if there is no marker.
+func readMarker(folderPath string) string {
+	b, err := os.ReadFile(filepath.Join(folderPath, MARKER_FILE_NAME))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func writeMarker(folderPath string, client string) error {
+	return os.WriteFile(filepath.Join(folderPath, MARKER_FILE_NAME), []byte(client+"\n"), 0644)
+}
+
+// clientExists tells whether name is taken in tgkDir, as folder or as client in the store.
+func clientExists(tgkDir string, name string) bool {
+	return utils.Exists(filepath.Join(tgkDir, name)) || utils.Exists(manifestPath(tgkDir, name))
+}
+
+// freeClientName returns name, or the first of "name (1)", "name (2)", ... that is not taken in tgkDir.
+func freeClientName(tgkDir string, name string) string {
+	candidate := name
+	for i := 1; clientExists(tgkDir, candidate); i++ {
+		candidate = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return candidate
+}
+
+// validClientName checks name against the rules for folder names on Windows.
+func validClientName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return newCLIError(ERR_INVALID_NAME, "Name must not be empty.")
+	}
+	if utils.ContainsStringWord(FORBIDDEN_CHARS[:], name) {
+		return newCLIError(ERR_INVALID_NAME, "Supplied name must not contain forbidden character.")
+	}
+	return nil
+}
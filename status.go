@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"fastSwapper/utils"
+)
+
+// status collects everything worth knowing before swapping: where the settings are, whether the folders they name
+// exist, which client the addin folder really holds, what Excel is doing and whether a swap was interrupted.
+
+type statusResult struct {
+	SettingsFile    string `json:"settingsfile"`
+	Tgkdir          string `json:"tgkdir"`
+	TgkdirExists    bool   `json:"tgkdirexists"`
+	Tgkfolder       string `json:"tgkfolder"`
+	TgkfolderExists bool   `json:"tgkfolderexists"`
+	StorageMode     string `json:"storagemode"`
+	// active client according to the settings
+	Active string `json:"active"`
+	// active client according to the marker inside Tgkfolder, empty if there is none
+	ActiveByMarker  string       `json:"activebymarker"`
+	Previous        string       `json:"previous"`
+	InactiveClients []string     `json:"inactiveclients"`
+	ExcelPIDs       []int32      `json:"excelpids"`
+	UnfinishedSwap  *swapJournal `json:"unfinishedswap,omitempty"`
+	// -1 if it could not be determined
+	FreeBytes int64 `json:"freebytes"`
+}
+
+func newStatusResult(set Settings) statusResult {
+	tgkDir := set.Defaults.Tgkdir
+	tgkDirPath := filepath.Join(tgkDir, set.Defaults.Tgkfolder)
+	storageMode := set.Defaults.StorageMode
+	if storageMode == "" {
+		storageMode = STORAGE_MODE_FOLDERS
+	}
+	r := statusResult{
+		SettingsFile:    settingsFilePath(),
+		Tgkdir:          tgkDir,
+		TgkdirExists:    tgkDir != "" && utils.Exists(tgkDir),
+		Tgkfolder:       set.Defaults.Tgkfolder,
+		TgkfolderExists: set.Defaults.Tgkfolder != "" && utils.Exists(tgkDirPath),
+		StorageMode:     storageMode,
+		Active:          set.ActiveSettings.OldDirectory,
+		Previous:        set.ActiveSettings.PreviousDirectory,
+		InactiveClients: make([]string, 0),
+		FreeBytes:       -1,
+	}
+	if r.TgkfolderExists {
+		r.ActiveByMarker = readMarker(tgkDirPath)
+	}
+	// a missing Tgkdir is what status should tell about, not fail on
+	if r.TgkdirExists {
+		if clients, err := DirectoriesInTgkDirExcludingTgkFolder(); err == nil {
+			r.InactiveClients = clients
+		} else {
+			slog.Warn("could not list clients", "error", err)
+		}
+		if j, ok, err := readJournal(tgkDir); ok {
+			r.UnfinishedSwap = &j
+		} else if err != nil {
+			slog.Warn("could not read swap journal", "error", err)
+		}
+		if free, err := utils.FreeDiskSpace(tgkDir); err == nil {
+			r.FreeBytes = int64(free)
+		} else {
+			slog.Warn("could not determine free disk space", "path", tgkDir, "error", err)
+		}
+	}
+	pids, err := utils.FindProcessesByName(EXCEL_PROCESS_NAME)
+	if err != nil {
+		slog.Warn("could not list processes", "error", err)
+	}
+	r.ExcelPIDs = pids
+	return r
+}
+
+// Lines returns the status as label/value lines, shared by the CLI and the TUI header.
+func (r statusResult) Lines() [][2]string {
+	existence := func(ok bool) string {
+		if ok {
+			return ""
+		}
+		return " (missing)"
+	}
+	active := r.Active
+	if r.ActiveByMarker != "" && r.ActiveByMarker != r.Active {
+		active += fmt.Sprintf(" (the addin folder says %s)", r.ActiveByMarker)
+	}
+	previous := r.Previous
+	if previous == "" {
+		previous = "-"
+	}
+	excel := "not running"
+	if len(r.ExcelPIDs) > 0 {
+		excel = "running, pid " + strings.Join(utils.Map(r.ExcelPIDs, func(p int32) string { return fmt.Sprint(p) }), ", ")
+	}
+	unfinished := "none"
+	if r.UnfinishedSwap != nil {
+		j := r.UnfinishedSwap
+		unfinished = fmt.Sprintf("%s -> %s, started %s, stopped at: %s", j.From, j.To, j.Started.Local().Format("2006-01-02 15:04:05"), j.Step)
+	}
+	free := "unknown"
+	if r.FreeBytes >= 0 {
+		free = utils.FormatBytes(uint64(r.FreeBytes))
+	}
+	return [][2]string{
+		{"settings file", r.SettingsFile},
+		{"tgkdir", r.Tgkdir + existence(r.TgkdirExists)},
+		{"tgkfolder", r.Tgkfolder + existence(r.TgkfolderExists)},
+		{"storage mode", r.StorageMode},
+		{"active client", active},
+		{"previous client", previous},
+		{"inactive clients", strings.Join(r.InactiveClients, ", ")},
+		{"excel", excel},
+		{"unfinished swap", unfinished},
+		{"free disk space", free},
+	}
+}
+
+func (r statusResult) String() string {
+	s := ""
+	for _, l := range r.Lines() {
+		s += fmt.Sprintf("%-17s %s\n", l[0]+":", l[1])
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newStatusResult(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A", PreviousDirectory: "Kunde B"},
+	}
+	useSettings(t, set)
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde C\n"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "b"})
+	if err := writeJournal(tgkDir, swapJournal{Started: time.Now(), From: "Kunde A", To: "Kunde B", Step: "rename incoming folder"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newStatusResult(set)
+	if !r.TgkdirExists || !r.TgkfolderExists {
+		t.Errorf("Existing folders reported missing: %+v", r)
+	}
+	if r.StorageMode != STORAGE_MODE_FOLDERS {
+		t.Errorf("Empty storage mode reported as %q", r.StorageMode)
+	}
+	if r.Active != "Kunde A" || r.ActiveByMarker != "Kunde C" || r.Previous != "Kunde B" {
+		t.Errorf("Clients reported as active %q, by marker %q, previous %q", r.Active, r.ActiveByMarker, r.Previous)
+	}
+	// the metadata directory is no client
+	if !reflect.DeepEqual(r.InactiveClients, []string{"Kunde B"}) {
+		t.Errorf("Inactive clients are %v, want [Kunde B]", r.InactiveClients)
+	}
+	if r.UnfinishedSwap == nil || r.UnfinishedSwap.Step != "rename incoming folder" {
+		t.Errorf("Unfinished swap not reported: %+v", r.UnfinishedSwap)
+	}
+	text := r.String()
+	for _, want := range []string{"(the addin folder says Kunde C)", "stopped at: rename incoming folder"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Missing %q in:\n%s", want, text)
+		}
+	}
+}
+
+func Test_newStatusResult_missingTgkdir(t *testing.T) {
+	set := Settings{
+		Defaults:       Default{Tgkdir: filepath.Join(t.TempDir(), "gone"), Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	useSettings(t, set)
+	r := newStatusResult(set)
+	if r.TgkdirExists || r.TgkfolderExists || len(r.InactiveClients) != 0 || r.UnfinishedSwap != nil || r.FreeBytes != -1 {
+		t.Errorf("Missing Tgkdir reported as %+v", r)
+	}
+	if text := r.String(); !strings.Contains(text, "(missing)") || !strings.Contains(text, "free disk space:  unknown") {
+		t.Errorf("Missing Tgkdir not shown as such:\n%s", text)
+	}
+}
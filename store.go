@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fastSwapper/utils"
+)
+
+// The store keeps inactive clients deduplicated inside the tagetik directory:
+//
+//	<Tgkdir>/.fastSwapper/objects/<first two chars of hash>/<sha256>   one blob per distinct file content
+//	<Tgkdir>/.fastSwapper/manifests/<client>.json                      which blob goes where for that client
+//
+// Only the active client exists as a real folder (Tgkfolder). Blobs are written read only and are never handed out:
+// materialising copies them, because Tagetik writes to the files of the active client and a write through a hardlink
+// would silently change the blob, and with it the file of every client sharing that content.
+
+type manifestEntry struct {
+	Path string      `json:"path"` // slash separated and relative to the client folder
+	Size int64       `json:"size"`
+	Hash string      `json:"sha256"`
+	Mode os.FileMode `json:"mode"`
+}
+
+type clientManifest struct {
+	Client string          `json:"client"`
+	Dirs   []string        `json:"dirs"`
+	Files  []manifestEntry `json:"files"`
+}
+
+func metaDirPath(tgkDir string) string {
+	return filepath.Join(tgkDir, META_DIR_NAME)
+}
+
+func objectsDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "objects")
+}
+
+func manifestsDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "manifests")
+}
+
+func manifestPath(tgkDir string, client string) string {
+	return filepath.Join(manifestsDirPath(tgkDir), client+".json")
+}
+
+func blobPath(tgkDir string, hash string) string {
+	return filepath.Join(objectsDirPath(tgkDir), hash[:2], hash)
+}
+
+// scanFolder hashes every regular file below root and returns the result as a manifest for client.
+func scanFolder(root string, client string) (clientManifest, error) {
+	m := clientManifest{Client: client}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		// the marker belongs to the swapper, not to the client
+		if rel == MARKER_FILE_NAME {
+			return nil
+		}
+		if d.IsDir() {
+			m.Dirs = append(m.Dirs, rel)
+			return nil
+		}
+		// symlinks and other special files are not part of an addin folder, skip them
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := utils.HashFile(path)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, manifestEntry{Path: rel, Size: info.Size(), Hash: hash, Mode: info.Mode().Perm()})
+		return nil
+	})
+	return m, err
+}
+
+func readManifest(path string) (clientManifest, error) {
+	var m clientManifest
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+func writeManifest(path string, m clientManifest) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// storedClients returns the names of all clients that have a manifest in the store.
+func storedClients(tgkDir string) []string {
+	result := make([]string, 0)
+	entries, err := os.ReadDir(manifestsDirPath(tgkDir))
+	if err != nil {
+		// no store yet
+		return result
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			result = append(result, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	return result
+}
+
+// storeIngest adds the folder at folderPath to the store under the name client. The folder itself is left untouched.
+func storeIngest(tgkDir string, client string, folderPath string) (clientManifest, error) {
+	m, err := scanFolder(folderPath, client)
+	if err != nil {
+		return m, err
+	}
+	for _, f := range m.Files {
+		blob := blobPath(tgkDir, f.Hash)
+		if utils.Exists(blob) {
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(blob), 0755)
+		if err != nil {
+			return m, err
+		}
+		// write to a temporary name first so an interrupted ingest never leaves a truncated blob under a valid hash
+		tmp := blob + ".tmp"
+		os.Remove(tmp)
+		err = utils.CopyFile(filepath.Join(folderPath, filepath.FromSlash(f.Path)), tmp, 0444)
+		if err != nil {
+			return m, err
+		}
+		err = os.Rename(tmp, blob)
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, writeManifest(manifestPath(tgkDir, client), m)
+}
+
+// storeFolder moves a plain client folder into the store.
+func storeFolder(tgkDir string, client string, folderPath string) error {
+	_, err := storeIngest(tgkDir, client, folderPath)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(folderPath)
+}
+
+// storeMaterialise recreates the client described by m at dest, which must not exist yet.
+func storeMaterialise(tgkDir string, m clientManifest, dest string) error {
+	err := os.Mkdir(dest, 0755)
+	if err != nil {
+		return err
+	}
+	for _, d := range m.Dirs {
+		err = os.MkdirAll(filepath.Join(dest, filepath.FromSlash(d)), 0755)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range m.Files {
+		blob := blobPath(tgkDir, f.Hash)
+		target := filepath.Join(dest, filepath.FromSlash(f.Path))
+		if !utils.Exists(blob) {
+			return fmt.Errorf("Store is missing the blob for %s of client %s.", f.Path, m.Client)
+		}
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+		mode := f.Mode
+		if mode == 0 {
+			// manifests written before the mode was recorded
+			mode = 0644
+		}
+		err = utils.CopyFile(blob, target, mode)
+		if err != nil {
+			return err
+		}
+		// CopyFile's mode is subject to the umask
+		err = os.Chmod(target, mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swapFromStore replaces Tgkfolder with a copy of newDirName materialised from the store.
+// The outgoing client is recorded in the store under oldDirName first, so nothing is lost if a later step fails.
+func swapFromStore(set Settings, newDirName string, step func(string)) error {
+	tgkDir := set.Defaults.Tgkdir
+	tgkDirPath := filepath.Join(tgkDir, set.Defaults.Tgkfolder)
+	newDirPath := filepath.Join(tgkDir, newDirName)
+	// a client that still is a plain folder (p.e. freshly copied into Tgkdir) is moved into the store first
+	if utils.Exists(newDirPath) {
+		step("move incoming folder into store")
+		slog.Info("swap step", "step", "move incoming folder into store", "client", newDirName)
+		err := storeFolder(tgkDir, newDirName, newDirPath)
+		if err != nil {
+			return err
+		}
+	}
+	m, err := readManifest(manifestPath(tgkDir, newDirName))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Client to swap in exists neither as folder nor in the store: %s", newDirName)
+	}
+	if err != nil {
+		return err
+	}
+	// 1. record the outgoing client. Its own manifest is expected and updated, the one of another client is not.
+	// Without a marker there is no telling which client the folder came from, so the manifest is taken to be its own.
+	marker := readMarker(tgkDirPath)
+	if marker != "" && marker != set.ActiveSettings.OldDirectory && utils.Exists(manifestPath(tgkDir, set.ActiveSettings.OldDirectory)) {
+		name := set.ActiveSettings.OldDirectory
+		return &collisionError{Name: name, Path: manifestPath(tgkDir, name), Suggestion: freeClientName(tgkDir, name)}
+	}
+	step("store outgoing client")
+	slog.Info("swap step", "step", "store outgoing client", "client", set.ActiveSettings.OldDirectory)
+	_, err = storeIngest(tgkDir, set.ActiveSettings.OldDirectory, tgkDirPath)
+	if err != nil {
+		return err
+	}
+	// 2. build the incoming client inside Tgkdir so the final rename stays on the same volume
+	staging := filepath.Join(metaDirPath(tgkDir), "staging")
+	outgoing := filepath.Join(metaDirPath(tgkDir), "outgoing")
+	os.RemoveAll(staging)
+	os.RemoveAll(outgoing)
+	step("materialise incoming client")
+	slog.Info("swap step", "step", "materialise incoming client", "client", newDirName, "files", len(m.Files))
+	err = storeMaterialise(tgkDir, m, staging)
+	if err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	// 3. move the outgoing folder out of the way and put the new one in its place
+	step("replace addin folder")
+	slog.Info("swap step", "step", "replace addin folder", "path", tgkDirPath)
+	err = os.Rename(tgkDirPath, outgoing)
+	if err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	err = os.Rename(staging, tgkDirPath)
+	if err != nil {
+		// put the old client back, otherwise there would be no addin folder at all
+		slog.Error("could not move materialised client into place, putting the outgoing one back", "error", err)
+		os.Rename(outgoing, tgkDirPath)
+		return err
+	}
+	return os.RemoveAll(outgoing)
+}
+
+// storeGC deletes every blob no manifest references anymore and returns how many blobs and bytes were freed.
+func storeGC(tgkDir string) (int, int64, error) {
+	referenced := make(map[string]struct{})
+	for _, client := range storedClients(tgkDir) {
+		m, err := readManifest(manifestPath(tgkDir, client))
+		if err != nil {
+			// never delete anything based on an incomplete picture
+			return 0, 0, fmt.Errorf("Could not read manifest of %s, nothing was removed: %w", client, err)
+		}
+		for _, f := range m.Files {
+			referenced[f.Hash] = struct{}{}
+		}
+	}
+	// clients in the trash can still be restored
+	for _, path := range trashedManifests(tgkDir) {
+		if !utils.Exists(path) {
+			continue
+		}
+		m, err := readManifest(path)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Could not read manifest %s, nothing was removed: %w", path, err)
+		}
+		for _, f := range m.Files {
+			referenced[f.Hash] = struct{}{}
+		}
+	}
+	removed := 0
+	var freed int64
+	if !utils.Exists(objectsDirPath(tgkDir)) {
+		return removed, freed, nil
+	}
+	err := filepath.WalkDir(objectsDirPath(tgkDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, ok := referenced[d.Name()]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+		slog.Debug("blob removed", "hash", d.Name(), "bytes", info.Size())
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates the files in files, relative to root, with the given contents.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countBlobs(t *testing.T, tgkDir string) int {
+	t.Helper()
+	count := 0
+	filepath.WalkDir(objectsDirPath(tgkDir), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+func Test_storeIngest(t *testing.T) {
+	tgkDir := t.TempDir()
+	folder := filepath.Join(tgkDir, "Kunde A")
+	writeFiles(t, folder, map[string]string{
+		"addin.dll":        "binary",
+		"config/app.xml":   "<app/>",
+		"config/copy.xml":  "<app/>",
+		MARKER_FILE_NAME:   "Kunde A\n",
+		"empty/.gitignore": "",
+	})
+	m, err := storeIngest(tgkDir, "Kunde A", folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 4 {
+		t.Errorf("Manifest has %d files, want 4 without the marker: %v", len(m.Files), m.Files)
+	}
+	// app.xml and copy.xml share their content
+	if got := countBlobs(t, tgkDir); got != 3 {
+		t.Errorf("Store has %d blobs, want 3", got)
+	}
+	info, err := os.Stat(blobPath(tgkDir, m.Files[0].Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0222 != 0 {
+		t.Errorf("Blob is writable: %s", info.Mode())
+	}
+	if _, err := os.Stat(folder); err != nil {
+		t.Errorf("Ingest touched the folder: %s", err)
+	}
+	if _, err := readManifest(manifestPath(tgkDir, "Kunde A")); err != nil {
+		t.Errorf("Could not read the manifest back: %s", err)
+	}
+}
+
+func Test_storeMaterialise(t *testing.T) {
+	tgkDir := t.TempDir()
+	folder := filepath.Join(tgkDir, "Kunde A")
+	writeFiles(t, folder, map[string]string{"config/app.xml": "<app/>", "run.cmd": "@echo off"})
+	if err := os.Chmod(filepath.Join(folder, "run.cmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(folder, "logs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m, err := storeIngest(tgkDir, "Kunde A", folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(tgkDir, "Addin")
+	if err := storeMaterialise(tgkDir, m, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "logs")); err != nil {
+		t.Errorf("Empty directory was not recreated: %s", err)
+	}
+	for rel, want := range map[string]os.FileMode{"config/app.xml": 0644, "run.cmd": 0755} {
+		info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has mode %s, want %s", rel, info.Mode().Perm(), want)
+		}
+	}
+	// the materialised files belong to the client alone, writing them must not reach the store
+	app := filepath.Join(dest, "config", "app.xml")
+	if err := os.WriteFile(app, []byte("<changed/>"), 0644); err != nil {
+		t.Fatalf("Materialised file is not writable: %s", err)
+	}
+	for _, f := range m.Files {
+		if f.Path != "config/app.xml" {
+			continue
+		}
+		b, err := os.ReadFile(blobPath(tgkDir, f.Hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "<app/>" {
+			t.Errorf("Writing the materialised file changed the blob to %q", b)
+		}
+	}
+	if err := storeMaterialise(tgkDir, m, dest); err == nil {
+		t.Errorf("Materialising into an existing folder did not fail")
+	}
+	os.RemoveAll(objectsDirPath(tgkDir))
+	if err := storeMaterialise(tgkDir, m, filepath.Join(tgkDir, "Other")); err == nil {
+		t.Errorf("Materialising with missing blobs did not fail")
+	}
+}
+
+func Test_storeGC(t *testing.T) {
+	tgkDir := t.TempDir()
+	writeFiles(t, filepath.Join(tgkDir, "A"), map[string]string{"shared.xml": "shared", "a.xml": "only a"})
+	writeFiles(t, filepath.Join(tgkDir, "B"), map[string]string{"shared.xml": "shared", "b.xml": "only b"})
+	for _, client := range []string{"A", "B"} {
+		if err := storeFolder(tgkDir, client, filepath.Join(tgkDir, client)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, _, err := storeGC(tgkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Errorf("GC removed %d blobs that are still referenced", removed)
+	}
+	if err := os.Remove(manifestPath(tgkDir, "B")); err != nil {
+		t.Fatal(err)
+	}
+	removed, freed, err := storeGC(tgkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || freed != int64(len("only b")) {
+		t.Errorf("GC removed %d blobs and %d bytes, want 1 and %d", removed, freed, len("only b"))
+	}
+	if got := countBlobs(t, tgkDir); got != 2 {
+		t.Errorf("Store has %d blobs left, want 2", got)
+	}
+	// an unreadable manifest must stop the GC before it deletes anything
+	if err := os.WriteFile(manifestPath(tgkDir, "Broken"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := storeGC(tgkDir); err == nil {
+		t.Errorf("GC ignored an unreadable manifest")
+	}
+	if got := countBlobs(t, tgkDir); got != 2 {
+		t.Errorf("GC removed blobs despite the unreadable manifest, %d left", got)
+	}
+}
+
+func Test_swapFromStore_outgoingCollision(t *testing.T) {
+	tgkDir := t.TempDir()
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{"app.xml": "active", MARKER_FILE_NAME: "Kunde A\n"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.xml": "b"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde C"), map[string]string{"app.xml": "c"})
+	for _, client := range []string{"Kunde B", "Kunde C"} {
+		if err := storeFolder(tgkDir, client, filepath.Join(tgkDir, client)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin", StorageMode: STORAGE_MODE_STORE},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde C"},
+	}
+	// the active client is Kunde A but would be saved as Kunde C, which is somebody else in the store
+	err := swapFromStore(set, "Kunde B", func(string) {})
+	var collision *collisionError
+	if !errors.As(err, &collision) {
+		t.Fatalf("Got %v, want a collision", err)
+	}
+	m, err := readManifest(manifestPath(tgkDir, "Kunde C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Client != "Kunde C" || len(m.Files) != 1 || m.Files[0].Size != 1 {
+		t.Errorf("Manifest of Kunde C was overwritten: %+v", m)
+	}
+	// saved under its own name the manifest it came from is simply updated
+	set.ActiveSettings.OldDirectory = "Kunde A"
+	if err := swapFromStore(set, "Kunde B", func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(tgkDir, "Addin", "app.xml")); string(b) != "b" {
+		t.Errorf("Addin folder holds %q after the swap, want the content of Kunde B", b)
+	}
+	if _, err := readManifest(manifestPath(tgkDir, "Kunde A")); err != nil {
+		t.Errorf("Outgoing client was not stored: %s", err)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	"fastSwapper/utils"
+)
+
+// Config templating lets clients share one set of addin files and differ only in a few config files:
+//
+//	<Tgkdir>/.fastSwapper/templates/<path inside Tgkfolder>   text/template, p.e. templates/Config/connection.config
+//	<Tgkdir>/.fastSwapper/vars/<client>.json                  flat object with that client's variables
+//	<Tgkdir>/.fastSwapper/originals/                          what the rendered files looked like before rendering
+//
+// When a client with a vars file is swapped in, every template is rendered into the new Tgkfolder and the files it
+// replaces are kept in originals. They are put back before the client is swapped out, so the client folder itself
+// never drifts.
+
+// written into originals/ next to the saved files
+type appliedTemplates struct {
+	Client string            `json:"client"`
+	Files  []appliedTemplate `json:"files"`
+}
+
+type appliedTemplate struct {
+	Path    string `json:"path"`    // slash separated and relative to Tgkfolder
+	Existed bool   `json:"existed"` // false if the file was created by rendering and has to be removed again
+}
+
+func templatesDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "templates")
+}
+
+func varsPath(tgkDir string, client string) string {
+	return filepath.Join(metaDirPath(tgkDir), "vars", client+".json")
+}
+
+func originalsDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "originals")
+}
+
+func appliedTemplatesPath(tgkDir string) string {
+	return filepath.Join(originalsDirPath(tgkDir), "applied.json")
+}
+
+// renderTemplates renders every template with the variables of client. It returns nil if client has no vars file.
+// Nothing is written, so this can run before a swap starts and fail it early.
+func renderTemplates(tgkDir string, client string) (map[string][]byte, error) {
+	b, err := os.ReadFile(varsPath(tgkDir, client))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	err = json.Unmarshal(b, &vars)
+	if err != nil {
+		return nil, fmt.Errorf("Variables of %s are not a flat JSON object of strings: %w", client, err)
+	}
+	rendered := make(map[string][]byte)
+	root := templatesDirPath(tgkDir)
+	if !utils.Exists(root) {
+		return rendered, nil
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// a variable missing for this client is an error, silently rendering "<no value>" into a config is worse
+		t, err := template.New(rel).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		err = t.Execute(&out, vars)
+		if err != nil {
+			return fmt.Errorf("Could not render %s for %s: %w", rel, client, err)
+		}
+		rendered[rel] = out.Bytes()
+		return nil
+	})
+	return rendered, err
+}
+
+// applyTemplates writes the rendered files into tgkFolderPath and keeps what they replace in originals. The record of
+// what is applied is written before any file is touched; if writing one fails the files written so far are put back.
+func applyTemplates(tgkDir string, tgkFolderPath string, client string, rendered map[string][]byte) error {
+	if len(rendered) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(rendered))
+	for rel := range rendered {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	err := saveOriginals(tgkDir, tgkFolderPath, client, paths)
+	if err != nil {
+		// nothing was rendered yet, only the originals saved so far have to go
+		return errors.Join(err, os.RemoveAll(originalsDirPath(tgkDir)))
+	}
+	for _, rel := range paths {
+		target := filepath.Join(tgkFolderPath, filepath.FromSlash(rel))
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if err == nil {
+			err = os.WriteFile(target, rendered[rel], 0644)
+		}
+		if err != nil {
+			slog.Error("could not write rendered template, restoring the originals", "client", client, "path", rel, "error", err)
+			return errors.Join(err, restoreTemplates(tgkDir, tgkFolderPath))
+		}
+		slog.Info("template rendered", "client", client, "path", rel)
+	}
+	return nil
+}
+
+// saveOriginals copies the files at paths inside tgkFolderPath that exist into originals and records all of them.
+func saveOriginals(tgkDir string, tgkFolderPath string, client string, paths []string) error {
+	applied := appliedTemplates{Client: client}
+	for _, rel := range paths {
+		target := filepath.Join(tgkFolderPath, filepath.FromSlash(rel))
+		_, err := os.Lstat(target)
+		existed := err == nil
+		if existed {
+			original := filepath.Join(originalsDirPath(tgkDir), filepath.FromSlash(rel))
+			err := os.MkdirAll(filepath.Dir(original), 0755)
+			if err != nil {
+				return err
+			}
+			err = utils.CopyFile(target, original, 0644)
+			if err != nil {
+				return err
+			}
+		}
+		applied.Files = append(applied.Files, appliedTemplate{Path: rel, Existed: existed})
+	}
+	b, err := json.MarshalIndent(applied, "", "    ")
+	if err != nil {
+		return err
+	}
+	// only created above if one of the files existed before
+	err = os.MkdirAll(originalsDirPath(tgkDir), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(appliedTemplatesPath(tgkDir), b, 0644)
+}
+
+func readAppliedTemplates(tgkDir string) (appliedTemplates, error) {
+	var applied appliedTemplates
+	b, err := os.ReadFile(appliedTemplatesPath(tgkDir))
+	if err != nil {
+		return applied, err
+	}
+	err = json.Unmarshal(b, &applied)
+	return applied, err
+}
+
+// restoreTemplates puts the originals back into tgkFolderPath. Does nothing if no templates are applied.
+func restoreTemplates(tgkDir string, tgkFolderPath string) error {
+	applied, err := writeOriginals(tgkDir, tgkFolderPath)
+	if err != nil || !applied {
+		return err
+	}
+	return os.RemoveAll(originalsDirPath(tgkDir))
+}
+
+// writeOriginals undoes the rendered templates in folderPath, which is Tgkfolder or a copy of it, and keeps the
+// originals themselves. applied is false if no templates are applied.
+func writeOriginals(tgkDir string, folderPath string) (applied bool, err error) {
+	templates, err := readAppliedTemplates(tgkDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, f := range templates.Files {
+		slog.Info("template restored", "client", templates.Client, "path", f.Path, "folder", folderPath)
+		target := filepath.Join(folderPath, filepath.FromSlash(f.Path))
+		if !f.Existed {
+			// never written if rendering stopped before it
+			if _, err := os.Lstat(target); err != nil {
+				continue
+			}
+			err = os.Remove(target)
+			if err != nil {
+				return true, err
+			}
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(originalsDirPath(tgkDir), filepath.FromSlash(f.Path)))
+		if err != nil {
+			return true, err
+		}
+		err = os.WriteFile(target, content, 0644)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// withoutTemplates turns a manifest of the active folder into one of the folder as it is without rendered
+// templates, so checksums are recorded and verified against what the client really contains.
+func withoutTemplates(tgkDir string, m clientManifest) (clientManifest, error) {
+	applied, err := readAppliedTemplates(tgkDir)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	existed := make(map[string]bool)
+	for _, f := range applied.Files {
+		existed[f.Path] = f.Existed
+	}
+	files := make([]manifestEntry, 0, len(m.Files))
+	for _, f := range m.Files {
+		wasThere, templated := existed[f.Path]
+		if !templated {
+			files = append(files, f)
+			continue
+		}
+		if !wasThere {
+			continue
+		}
+		original := filepath.Join(originalsDirPath(tgkDir), filepath.FromSlash(f.Path))
+		info, err := os.Stat(original)
+		if err != nil {
+			return m, err
+		}
+		f.Hash, err = utils.HashFile(original)
+		if err != nil {
+			return m, err
+		}
+		f.Size = info.Size()
+		files = append(files, f)
+	}
+	m.Files = files
+	return m, nil
+}
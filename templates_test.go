@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fastSwapper/utils"
+)
+
+func Test_templates_failedSwap(t *testing.T) {
+	tgkDir := t.TempDir()
+	addin := filepath.Join(tgkDir, "Addin")
+	writeFiles(t, addin, map[string]string{"config/app.config": "server=original"})
+	writeFiles(t, metaDirPath(tgkDir), map[string]string{
+		"templates/config/app.config": "server={{.Server}}",
+		"templates/config/new.config": "user={{.User}}",
+		"vars/Kunde A.json":           `{"Server": "srv-a", "User": "a"}`,
+	})
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	check := func(rel string, want string) {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(addin, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s holds %q, want %q", rel, b, want)
+		}
+	}
+
+	rendered, err := renderTemplates(tgkDir, "Kunde A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyTemplates(tgkDir, addin, "Kunde A", rendered); err != nil {
+		t.Fatal(err)
+	}
+	check("config/app.config", "server=srv-a")
+	check("config/new.config", "user=a")
+
+	// a swap to a client that does not exist fails before anything is touched
+	settingsFile := filepath.Join(t.TempDir(), SETTINGS_FILE_NAME)
+	if err := os.WriteFile(settingsFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceAddinFolder(set, "Nope", settingsFile, archiveMove{}); err == nil {
+		t.Fatal("Swap to a missing client did not fail")
+	}
+	check("config/app.config", "server=srv-a")
+	check("config/new.config", "user=a")
+	if !utils.Exists(appliedTemplatesPath(tgkDir)) {
+		t.Errorf("Failed swap removed %s", appliedTemplatesPath(tgkDir))
+	}
+	if _, ok, _ := readJournal(tgkDir); ok {
+		t.Errorf("Failed swap left a journal behind")
+	}
+
+	if err := restoreTemplates(tgkDir, addin); err != nil {
+		t.Fatal(err)
+	}
+	check("config/app.config", "server=original")
+	if utils.Exists(filepath.Join(addin, "config", "new.config")) {
+		t.Errorf("Rendered file that did not exist before was not removed")
+	}
+	if utils.Exists(originalsDirPath(tgkDir)) {
+		t.Errorf("Originals were not cleaned up")
+	}
+}
+
+func Test_renderTemplates_missingVariable(t *testing.T) {
+	tgkDir := t.TempDir()
+	writeFiles(t, metaDirPath(tgkDir), map[string]string{
+		"templates/app.config": "server={{.Server}}",
+		"vars/Kunde A.json":    `{"User": "a"}`,
+	})
+	if _, err := renderTemplates(tgkDir, "Kunde A"); err == nil {
+		t.Errorf("Template with a missing variable rendered")
+	}
+	if rendered, err := renderTemplates(tgkDir, "Kunde B"); rendered != nil || err != nil {
+		t.Errorf("Client without vars got %v, %v", rendered, err)
+	}
+}
+
+func Test_applyTemplates_failedWrite(t *testing.T) {
+	tgkDir := t.TempDir()
+	addin := filepath.Join(tgkDir, "Addin")
+	// a file where the folder of the second template would go, so it cannot be written
+	writeFiles(t, addin, map[string]string{"a.config": "original", "locked": "in the way"})
+	rendered := map[string][]byte{"a.config": []byte("rendered"), "locked/b.config": []byte("rendered")}
+	if err := applyTemplates(tgkDir, addin, "Kunde A", rendered); err == nil {
+		t.Fatal("Rendering into a file went through")
+	}
+	b, err := os.ReadFile(filepath.Join(addin, "a.config"))
+	if err != nil || string(b) != "original" {
+		t.Errorf("a.config holds %q, %v after the failed render, want the original", b, err)
+	}
+	if utils.Exists(originalsDirPath(tgkDir)) {
+		t.Errorf("Failed render left %s behind", originalsDirPath(tgkDir))
+	}
+}
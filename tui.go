@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
+	"path/filepath"
+	"sort"
 	"strings"
-	"unicode/utf8"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -14,20 +17,26 @@ import (
 )
 
 var (
-	mainColor          = tuiAssets.GetDefaultColor()
-	footerColor        = tuiAssets.GREY
-	headerColor        = tuiAssets.GREY
-	choicesColor       = tuiAssets.WHITE
-	choiceStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(choicesColor))
-	keywordStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color(mainColor))
-	cursorStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(mainColor))
-	footerStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(footerColor))
-	headerStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(headerColor))
-	boxStyle           = lipgloss.NewStyle().Foreground(lipgloss.Color(mainColor))
+	theme              = tuiAssets.GetDefaultTheme()
+	choiceStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Choices))
+	keywordStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Accent))
+	cursorStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Accent))
+	footerStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Footer))
+	headerStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Header))
+	boxStyle           = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Accent))
+	selectionStyle     = lipgloss.NewStyle().Background(lipgloss.Color(theme.Selection))
 	activeBox          = tuiAssets.GetDefaultBox()
-	footerItems        = []string{"q: quit", "u: swap", "c: change colors", "b: change box"}
-	numFooterRows      = 2
+	addedStyle         = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Success))
+	removedStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Error))
+	promptFooterItems  = []string{"enter: confirm", "esc: cancel"}
+	filterFooterItems  = []string{"up/down/pgup/pgdown: move", "enter: select", "esc: clear filter"}
+	helpFooterItems    = []string{"any key: close"}
+	pagerPageSize      = 20
+	watchPollInterval  = 2 * time.Second
+	numFooterRows      = 5
 	cursorSymbol       = ">"
+	moreAboveSymbol    = "\u2191"
+	moreBelowSymbol    = "\u2193"
 	checkmarkSymbol    = "x"
 	leftbracketSymbol  = "["
 	rightbracketSymbol = "]"
@@ -40,39 +49,199 @@ type model struct {
 	selected     map[int]struct{}
 	lastSelected *int
 	active       string
+	// the expanded header with the full status, nil while collapsed
+	status *statusResult
+	// the pager shows the diff and history views on top of the list, it is open while pagerLines is not nil
+	pager       string
+	pagerLines  []string
+	pagerOffset int
+	// a one line text prompt below the list, open while prompt is not empty
+	prompt       string
+	promptLabel  string
+	promptInput  []rune
+	promptTarget string
+	// shown above the list until the next key press, p.e. why a swap failed
+	message string
+	// the incremental filter, open while filtering is set. matches is ordered best first.
+	filtering bool
+	filter    []rune
+	matches   []choiceMatch
+	// client info searched by the filter and used for grouping
+	infos map[string]clientInfo
+	// one of sortModes and groupModes, stats is only collected for the sort modes that need it
+	sortMode  string
+	groupMode string
+	stats     map[string]clientStats
+	// details of the entry under the cursor, collected in the background and kept until the list is reloaded
+	details        map[string]clientDetails
+	detailsLoading map[string]bool
+	// terminal size from the last tea.WindowSizeMsg, zero until the first one arrives
+	width  int
+	height int
+	// index into listRows of the first row shown, kept by scrollToCursor
+	listOffset int
+	// the key bindings, the help overlay is open while help is set to the keymap mode it was opened from
+	keys keymap
+	help string
+	// the themes offered by the theme picker, loaded when it opens
+	themes []tuiAssets.Theme
 }
 
-// initialization of a new model
-func mainModel(dirs []string, activeVersion string) model {
-	return model{
+// detailsMsg carries the details collected by loadDetails back into Update.
+type detailsMsg clientDetails
+
+// tgkDirChangedMsg is sent by the watcher started in runTui when something in Tgkdir changed.
+type tgkDirChangedMsg struct{}
+
+// choiceMatch is an entry of m.choices that matches the filter, in its name or in one of the fields of its info.
+type choiceMatch struct {
+	index int
+	score int
+	// empty if the name matched
+	field     string
+	value     string
+	positions []int
+}
+
+// mainModel builds the model of the list; warnings are problems with the settings found before, p.e. by runTui.
+func mainModel(dirs []string, activeVersion string, warnings []string) model {
+	tuiSettings := getTuiSettings(SETTINGS_FILE_NAME)
+	m := model{
 		// choices:  []string{"Buy carrots", "Buy celery", "Do somthing else"},
-		choices:      dirs,
-		selected:     make(map[int]struct{}),
-		lastSelected: nil,
-		active:       activeVersion,
+		selected:       make(map[int]struct{}),
+		lastSelected:   nil,
+		active:         activeVersion,
+		sortMode:       tuiSettings.Sort,
+		groupMode:      tuiSettings.Group,
+		details:        make(map[string]clientDetails),
+		detailsLoading: make(map[string]bool),
+	}
+	// problems with the settings are shown until the first key press
+	keys, err := loadKeymap(tuiSettings.Keys)
+	if err != nil {
+		slog.Warn("invalid key bindings in the settings, using the defaults", "error", err)
+		warnings = append(warnings, "Key bindings in the settings ignored: "+err.Error())
+	}
+	m.keys = keys
+	m.message = strings.Join(warnings, "\n")
+	if !utils.ContainsString(sortModes, m.sortMode) {
+		m.sortMode = SORT_NAME
+	}
+	if !utils.ContainsString(groupModes, m.groupMode) {
+		m.groupMode = GROUP_NONE
 	}
+	return m.setChoices(dirs)
 }
 
-// Init is used when we want to do IO, for now we dont need it so it returns nil
+// Init starts collecting the details of the first entry
 func (m model) Init() tea.Cmd {
-	return nil
+	return m.loadDetails()
 }
 
 // this should be used to update the model > when we swap folders the list of choices needs to be refreshed
-// this currently keeps the cursor position and selection! if the order of choices would change this would lead to wrong highlighting
+// the selection is cleared, the cursor stays on the folder it was on
 func (m model) UpdateChoices() tea.Model {
-	dirsWithOutTgkFolder := DirectoriesInTgkDirExcludingTgkFolder()
-	m.choices = dirsWithOutTgkFolder
+	dirsWithOutTgkFolder, err := DirectoriesInTgkDirExcludingTgkFolder()
+	if err != nil {
+		m.message = err.Error()
+		return m
+	}
 	m.lastSelected = nil
 	m.selected = make(map[int]struct{})
 	m.active = GetActiveVersion()
+	m.infos = nil
+	m.stats = nil
+	m.details = make(map[string]clientDetails)
+	m.detailsLoading = make(map[string]bool)
+	m = m.setChoices(dirsWithOutTgkFolder)
+	if m.status != nil {
+		m = m.refreshStatus()
+	}
+	return m
+}
+
+// refreshChoices reloads the list after a change on disk nobody asked for, unlike UpdateChoices the selection is kept.
+func (m model) refreshChoices() model {
+	// p.e. a network share that went away
+	clients, err := DirectoriesInTgkDirExcludingTgkFolder()
+	if err != nil {
+		slog.Warn("could not refresh the list", "error", err)
+		m.message = "The tagetik directory is not reachable anymore."
+		return m
+	}
+	m.active = GetActiveVersion()
+	m.infos = nil
+	m.stats = nil
+	// collecting details hashes the whole client, only the ones that changed are collected again
+	set := GetCompleteSettings(SETTINGS_FILE_NAME)
+	for client, d := range m.details {
+		if d.stamp != detailsStamp(set, client) {
+			delete(m.details, client)
+		}
+	}
+	m = m.setChoices(clients)
+	if m.status != nil {
+		m = m.refreshStatus()
+	}
+	return m
+}
+
+// setChoices replaces the list with choices arranged by the sort and group mode. Cursor and selection stay on the
+// folders they were on, not on the same index.
+func (m model) setChoices(choices []string) model {
+	cursorName := ""
+	if m.cursor < len(m.choices) {
+		cursorName = m.choices[m.cursor]
+	}
+	selectedName := ""
+	if m.lastSelected != nil && *m.lastSelected < len(m.choices) {
+		selectedName = m.choices[*m.lastSelected]
+	}
+	if m.infos == nil {
+		m.infos = loadClientInfos(GetTgkDir(), choices)
+	}
+	if m.stats == nil && sortNeedsStats(m.sortMode) {
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		lastUsed := lastUsedTimes(set.Defaults.Tgkdir)
+		m.stats = make(map[string]clientStats, len(choices))
+		for _, c := range choices {
+			m.stats[c] = collectClientStats(set, c, lastUsed)
+		}
+	}
+	m.choices = sortClients(choices, m.sortMode, m.groupMode, m.infos, m.stats)
+	m.selected = make(map[int]struct{})
+	m.lastSelected = nil
+	for i, c := range m.choices {
+		if c == cursorName {
+			m.cursor = i
+		}
+		if c == selectedName {
+			selected := i
+			m.selected[selected] = struct{}{}
+			m.lastSelected = &selected
+		}
+	}
+	// the list may have become shorter, p.e. after a delete
+	if m.cursor >= len(m.choices) && len(m.choices) > 0 {
+		m.cursor = len(m.choices) - 1
+	}
+	if m.filtering {
+		m = m.matchFilter()
+	}
+	return m
+}
+
+// refreshStatus collects the status shown in the expanded header; listing processes is too slow to do on every frame.
+func (m model) refreshStatus() model {
+	status := newStatusResult(GetCompleteSettings(SETTINGS_FILE_NAME))
+	m.status = &status
 	return m
 }
 
-func (m model) swapFolders() error {
+// swapFolders swaps in the client the prompt was opened for and saves the active one as outgoingName.
+func (m model) swapFolders(outgoingName string) error {
 	var err error = nil
-	target := m.choices[m.cursor]
-	err = SwapDirectories(target)
+	err = SwapDirectoriesAs(m.promptTarget, outgoingName)
 	if err != nil {
 		return err
 	}
@@ -83,44 +252,158 @@ func killExcel(name string) error {
 	return utils.KillProcessByName(name)
 }
 
+// Update handles msg and then scrolls the list so the cursor stays in view.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	updated, cmd := m.update(msg)
+	return updated.(model).scrollToCursor(), cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	case detailsMsg:
+		delete(m.detailsLoading, msg.Client)
+		// changed while being collected, the list was refreshed in the meantime and they are collected again
+		if msg.stamp == detailsStamp(GetCompleteSettings(SETTINGS_FILE_NAME), msg.Client) {
+			m.details[msg.Client] = clientDetails(msg)
+		}
+	case tgkDirChangedMsg:
+		m = m.refreshChoices()
 	// Is it a key press?
 	case tea.KeyMsg:
+		m.message = ""
+		if m.help != "" {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			m.help = ""
+			return m, nil
+		}
+		if m.prompt != "" {
+			return m.updatePrompt(msg)
+		}
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
+		if m.pagerLines != nil {
+			return m.updatePager(msg)
+		}
 
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
 		// Cool, what was the actual key pressed?
-		switch msg.String() {
+		switch m.keys.action(KEYMAP_MODE_LIST, msg.String()) {
 
 		// These keys should exit the program.
-		case "ctrl+c", "q":
+		case ACTION_QUIT:
 			return m, tea.Quit
 
-		case "u":
-			// diplay pop window to check if user really wants to proceed, if not then restart mainModel
+		case ACTION_HELP:
+			m.help = KEYMAP_MODE_LIST
+
+		case ACTION_SWAP:
+			// the confirmation asks under which name the active client is saved, prefilled with what it most likely is
 			// TO DO: add the logic so this does not directly kill excel but informs the user first.
 			// if any entry is selected, make the swapping.
 			if len(m.selected) > 0 {
-				m.swapFolders()
+				target := m.choices[m.cursor]
+				set := getSettings(SETTINGS_FILE_NAME)
+				outgoing := readMarker(filepath.Join(set.Tgkdir, set.Tgkfolder))
+				if outgoing == "" {
+					outgoing = m.active
+				}
+				return m.openPrompt(PROMPT_OUTGOING_NAME, "Swap in "+target+" and save the active client as: ", outgoing, target), nil
 			}
-			return m.UpdateChoices(), nil
+			return m, nil
 
-		case "up", "k":
+		case ACTION_SWAP_BACK:
+			err := SwapBack()
+			m = m.UpdateChoices().(model)
+			if err != nil {
+				m.message = err.Error()
+			}
+			return m, nil
+
+		case ACTION_UP:
 			if m.cursor > 0 {
 				m.cursor--
 			}
 
-		case "down", "j":
+		case ACTION_DOWN:
 			if m.cursor < len(m.choices)-1 {
 				m.cursor++
 			}
 
-		case "c":
-			changeColors()
-		case "b":
-			changeBoxStyle()
+		case ACTION_PAGE_UP:
+			m = m.moveCursor(-m.pageSize())
+		case ACTION_PAGE_DOWN:
+			m = m.moveCursor(m.pageSize())
+		case ACTION_HOME:
+			m = m.moveCursor(-len(m.choices))
+		case ACTION_END:
+			m = m.moveCursor(len(m.choices))
+
+		case ACTION_COLORS:
+			changeColors(true)
+		case ACTION_COLORS_BACK:
+			changeColors(false)
+		case ACTION_BOX:
+			if err := changeBoxStyle(true); err != nil {
+				m.message = err.Error()
+			}
+		case ACTION_BOX_BACK:
+			if err := changeBoxStyle(false); err != nil {
+				m.message = err.Error()
+			}
+		case ACTION_THEMES:
+			m = m.openThemePicker()
+		case ACTION_DIFF:
+			m = m.openDiffView()
+		case ACTION_HISTORY:
+			m = m.openHistoryView()
+		case ACTION_LOG:
+			m = m.openLogView()
+		case ACTION_RENAME:
+			if len(m.choices) > 0 {
+				target := m.choices[m.cursor]
+				return m.openPrompt(PROMPT_RENAME, "Rename "+target+" to: ", target, target), nil
+			}
+		case ACTION_DUPLICATE:
+			if len(m.choices) > 0 {
+				target := m.choices[m.cursor]
+				return m.openPrompt(PROMPT_DUPLICATE, "Duplicate "+target+" as: ", freeClientName(GetTgkDir(), target), target), nil
+			}
+		case ACTION_DELETE:
+			if len(m.choices) > 0 {
+				target := m.choices[m.cursor]
+				return m.openPrompt(PROMPT_DELETE, "Move to the trash, enter to confirm: ", target, target), nil
+			}
+		case ACTION_TRASH:
+			m = m.openTrashView()
+		case ACTION_SORT:
+			m.sortMode = nextMode(sortModes, m.sortMode)
+			setTuiSettings(SETTINGS_FILE_NAME, "Sort", m.sortMode)
+			m = m.setChoices(m.choices)
+		case ACTION_GROUP:
+			m.groupMode = nextMode(groupModes, m.groupMode)
+			setTuiSettings(SETTINGS_FILE_NAME, "Group", m.groupMode)
+			m = m.setChoices(m.choices)
+		case ACTION_FILTER:
+			m.filtering = true
+			m.filter = nil
+			m = m.applyFilter()
+		case ACTION_STATUS:
+			if m.status == nil {
+				m = m.refreshStatus()
+			} else {
+				m.status = nil
+			}
 
 		// the selected state for the item that the cursor is pointing at.
-		case "enter", " ":
+		case ACTION_SELECT:
 			_, ok := m.selected[m.cursor]
 			if ok {
 				delete(m.selected, m.cursor)
@@ -137,45 +420,758 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Return the updated model to the Bubble Tea runtime for processing.
-	// Note that we're not returning a command.
-	return m, nil
+	// The command collects the details of the entry under the cursor if they are not known yet.
+	return m, m.loadDetails()
+}
+
+// loadDetails returns a command collecting the details of the entry under the cursor, nil if there is nothing to do.
+func (m model) loadDetails() tea.Cmd {
+	if m.cursor >= len(m.choices) {
+		return nil
+	}
+	client := m.choices[m.cursor]
+	if _, ok := m.details[client]; ok || m.detailsLoading[client] {
+		return nil
+	}
+	m.detailsLoading[client] = true
+	return func() tea.Msg {
+		return detailsMsg(collectClientDetails(GetCompleteSettings(SETTINGS_FILE_NAME), client))
+	}
+}
+
+// detailsPane renders the details of the entry under the cursor, empty if the list is empty.
+func (m model) detailsPane() string {
+	if m.cursor >= len(m.choices) {
+		return ""
+	}
+	client := m.choices[m.cursor]
+	s := keywordStyle.Render(client) + "\n\n"
+	d, ok := m.details[client]
+	if !ok {
+		s += headerStyle.Render("collecting details...") + "\n"
+		return drawInBox(s, activeBox, m.width)
+	}
+	for _, l := range d.Lines() {
+		value := choiceStyle.Render(l[1])
+		if l[0] == "validation" && d.Err != nil {
+			value = removedStyle.Render(l[1])
+		} else if l[0] == "validation" && d.Verified {
+			value = addedStyle.Render(l[1])
+		}
+		s += headerStyle.Render(fmt.Sprintf("%-14s ", l[0]+":")) + value + "\n"
+	}
+	return drawInBox(s, activeBox, m.width)
+}
+
+// layout puts the details pane next to the list, or below it if the terminal is not wide enough for both.
+func (m model) layout(list string, details string, beside bool) string {
+	if details == "" {
+		return list + "\n"
+	}
+	if beside {
+		return lipgloss.JoinHorizontal(lipgloss.Top, list, details) + "\n"
+	}
+	return list + "\n" + details + "\n"
+}
+
+// detailsBeside tells whether details fit next to the list drawn from header and footer. It measures the whole list,
+// not just the rows in view, so the answer does not change while scrolling.
+func (m model) detailsBeside(header string, footer string, details string) bool {
+	if m.width <= 0 {
+		return false
+	}
+	list := drawInBox(header+m.listView(-1)+footer, activeBox, m.width)
+	return lipgloss.Width(list)+lipgloss.Width(details) <= m.width
 }
 
 func (m model) View() string {
-	// The header
+	if m.help != "" {
+		return m.helpView()
+	}
+	if m.pagerLines != nil {
+		return m.pagerView()
+	}
+	header := m.headerView()
+	footer := m.footerView()
+	details := m.detailsPane()
+	list := drawInBox(header+m.listView(m.listHeight(header, footer))+footer, activeBox, m.width)
+	return m.layout(list, details, m.detailsBeside(header, footer, details))
+}
+
+func (m model) headerView() string {
 	s := headerStyle.Render("Please chose which version to swap in.") + "\n"
-	s += headerStyle.Render("Currently active: ") + keywordStyle.Render(m.active) + "\n\n"
-	// Iterate over our choices
-	for i, choice := range m.choices {
+	s += headerStyle.Render("Currently active: ") + keywordStyle.Render(m.active) + "\n"
+	if m.message != "" {
+		s += removedStyle.Render(m.message) + "\n"
+	}
+	if m.status != nil {
+		for _, l := range m.status.Lines() {
+			s += headerStyle.Render(fmt.Sprintf("%-17s ", l[0]+":")) + choiceStyle.Render(l[1]) + "\n"
+		}
+	}
+	arrangement := "sorted by " + m.sortMode
+	if m.groupMode != GROUP_NONE {
+		arrangement += ", grouped by " + m.groupMode
+	}
+	return s + headerStyle.Render(arrangement) + "\n\n"
+}
 
-		// Is the cursor pointing at this choice?
-		cursor := " " // no cursor
-		if m.cursor == i {
-			cursor = cursorStyle.Render(cursorSymbol) // cursor!
+// footerView renders what comes below the list: the filter or prompt line if open, and the footer.
+func (m model) footerView() string {
+	s := ""
+	if m.filtering {
+		if len(m.matches) == 0 {
+			s += headerStyle.Render("  no matches") + "\n"
 		}
+		s += "\n" + keywordStyle.Render("/") + choiceStyle.Render(string(m.filter)) + cursorStyle.Render("_") + "\n"
+		return s + "\n" + drawFooter(filterFooterItems, 1)
+	}
+	if m.prompt != "" {
+		s += "\n" + headerStyle.Render(m.promptLabel) + keywordStyle.Render(string(m.promptInput)) + cursorStyle.Render("_") + "\n"
+		return s + "\n" + drawFooter(promptFooterItems, 1)
+	}
+	return s + "\n" + footerStyle.Render(markersLegend()) + "\n" + drawFooter(m.keys.footer(KEYMAP_MODE_LIST, ""), numFooterRows)
+}
+
+// markersLegend explains the markers in front of the entries, cursor and selection are not told apart by color alone.
+func markersLegend() string {
+	return cursorSymbol + ": cursor   " + leftbracketSymbol + checkmarkSymbol + rightbracketSymbol + ": selected"
+}
+
+// listRow is a line of the list: an entry of m.choices, or the label of a group if match is nil.
+type listRow struct {
+	match *choiceMatch
+	group string
+}
+
+// listRows returns the lines of the list in the order they are shown.
+func (m model) listRows() []listRow {
+	rows := make([]listRow, 0, len(m.choices))
+	group := ""
+	for _, match := range m.visibleChoices() {
+		choice := m.choices[match.index]
+		// the filter orders by score, groups would be torn apart
+		if m.groupMode != GROUP_NONE && !m.filtering && groupOf(m.groupMode, m.infos[choice]) != group {
+			group = groupOf(m.groupMode, m.infos[choice])
+			rows = append(rows, listRow{group: group})
+		}
+		rows = append(rows, listRow{match: &match})
+	}
+	return rows
+}
+
+// listHeight returns how many rows of the list fit into the terminal next to header and footer, -1 if the height of
+// the terminal is not known yet.
+func (m model) listHeight(header string, footer string) int {
+	if m.height <= 0 {
+		return -1
+	}
+	// the top and bottom of the box, its vertical padding, the last line of the footer and the line after the box
+	chrome := 6
+	height := m.height - chrome - strings.Count(header, "\n") - strings.Count(footer, "\n")
+	// below the list the details pane takes its rows from the list
+	if details := m.detailsPane(); details != "" && !m.detailsBeside(header, footer, details) {
+		height -= lipgloss.Height(details)
+	}
+	return max(height, 1)
+}
+
+// cursorRow returns the position of the cursor in rows, -1 if the entry under it is not shown.
+func (m model) cursorRow(rows []listRow) int {
+	for i, r := range rows {
+		if r.match != nil && r.match.index == m.cursor {
+			return i
+		}
+	}
+	return -1
+}
+
+// scrollToCursor moves the viewport of the list so the cursor is in it. It runs after every update since the cursor,
+// the list and the size of the terminal all may have changed.
+func (m model) scrollToCursor() model {
+	height := m.listHeight(m.headerView(), m.footerView())
+	rows := m.listRows()
+	if height < 0 || len(rows) <= height {
+		m.listOffset = 0
+		return m
+	}
+	// room for the lines telling what is above and below
+	height = max(height-2, 1)
+	if r := m.cursorRow(rows); r >= 0 {
+		if r < m.listOffset {
+			m.listOffset = r
+			// keep the label of the group the cursor is in
+			if r > 0 && rows[r-1].match == nil {
+				m.listOffset = r - 1
+			}
+		}
+		if r >= m.listOffset+height {
+			m.listOffset = r - height + 1
+		}
+	}
+	m.listOffset = min(max(m.listOffset, 0), len(rows)-height)
+	return m
+}
 
-		// Is this choice selected?
-		checked := " " // not selected
-		if _, ok := m.selected[i]; ok {
-			checked = cursorStyle.Render(checkmarkSymbol) // selected!
+// moveCursor moves the cursor by delta entries in the order they are shown, staying on the first or last one.
+func (m model) moveCursor(delta int) model {
+	visible := m.visibleChoices()
+	if len(visible) == 0 {
+		return m
+	}
+	current := 0
+	for i, match := range visible {
+		if match.index == m.cursor {
+			current = i
 		}
+	}
+	m.cursor = visible[min(max(current+delta, 0), len(visible)-1)].index
+	return m
+}
 
-		leftBracket := choiceStyle.Render(leftbracketSymbol)
-		rightBracket := choiceStyle.Render(rightbracketSymbol)
-		choice = choiceStyle.Render(choice)
-		// Render the row
-		s += fmt.Sprintf("%s %s%s%s %s\n", cursor, leftBracket, checked, rightBracket, choice)
+// pageSize returns how many entries page up and down move the cursor by.
+func (m model) pageSize() int {
+	height := m.listHeight(m.headerView(), m.footerView())
+	if height < 0 {
+		return pagerPageSize
 	}
+	return max(height-2, 1)
+}
 
-	// The footer
-	s += "\n" + drawInGrid(footerItems, numFooterRows)
-	s = drawInBox(s, activeBox) + "\n"
-	// Send the UI for rendering
+// listView renders the rows of the list that fit into height, with how many entries are left out above and below.
+// A negative height shows everything.
+func (m model) listView(height int) string {
+	rows := m.listRows()
+	start, end := 0, len(rows)
+	scrolling := height >= 0 && len(rows) > height
+	if scrolling {
+		height = max(height-2, 1)
+		start = min(m.listOffset, len(rows)-height)
+		end = start + height
+	}
+	s := ""
+	if scrolling {
+		s += headerStyle.Render(moreLine(moreAboveSymbol, countEntries(rows[:start]), "above")) + "\n"
+	}
+	for _, row := range rows[start:end] {
+		if row.match == nil {
+			s += keywordStyle.Render(row.group) + "\n"
+			continue
+		}
+		s += m.choiceRow(*row.match) + "\n"
+	}
+	if scrolling {
+		s += headerStyle.Render(moreLine(moreBelowSymbol, countEntries(rows[end:]), "below")) + "\n"
+	}
 	return s
 }
 
+func countEntries(rows []listRow) int {
+	n := 0
+	for _, r := range rows {
+		if r.match != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// moreLine tells how many entries are out of view in one direction, empty if none so the list does not jump.
+func moreLine(arrow string, n int, where string) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  %s %d more %s", arrow, n, where)
+}
+
+// choiceRow renders an entry of the list.
+func (m model) choiceRow(match choiceMatch) string {
+	i := match.index
+	choice := m.choices[i]
+
+	// Is the cursor pointing at this choice?
+	cursor := " " // no cursor
+	if m.cursor == i {
+		cursor = cursorStyle.Render(cursorSymbol) // cursor!
+	}
+
+	// Is this choice selected?
+	checked := " " // not selected
+	if _, ok := m.selected[i]; ok {
+		checked = cursorStyle.Render(checkmarkSymbol) // selected!
+	}
+
+	leftBracket := choiceStyle.Render(leftbracketSymbol)
+	rightBracket := choiceStyle.Render(rightbracketSymbol)
+	base, hl := choiceStyle, keywordStyle
+	if m.cursor == i {
+		base, hl = base.Inherit(selectionStyle), hl.Inherit(selectionStyle)
+	}
+	if match.field == "" {
+		choice = highlight(choice, match.positions, base, hl)
+	} else {
+		choice = base.Render(choice) + headerStyle.Render("  "+match.field+": ") + highlight(match.value, match.positions, headerStyle, hl)
+	}
+	// Render the row
+	return fmt.Sprintf("%s %s%s%s %s", cursor, leftBracket, checked, rightBracket, choice)
+}
+
+// openDiffView compares the selected entry, or the active client if nothing is selected, with the one under the cursor.
+func (m model) openDiffView() model {
+	if len(m.choices) == 0 {
+		return m
+	}
+	from := m.active
+	if m.lastSelected != nil {
+		from = m.choices[*m.lastSelected]
+	}
+	result, err := diffClients(GetCompleteSettings(SETTINGS_FILE_NAME), from, m.choices[m.cursor])
+	text := result.String()
+	if err != nil {
+		text = err.Error()
+	}
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\t", "    "), "\n"), "\n")
+	// the "--- a" / "+++ b" file headers of a unified diff, told apart from a removed "-- x" by coming as a pair
+	isFileHeader := func(i int) bool {
+		if strings.HasPrefix(lines[i], "--- ") {
+			return i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+		}
+		return strings.HasPrefix(lines[i], "+++ ") && i > 0 && strings.HasPrefix(lines[i-1], "--- ")
+	}
+	headers := make(map[int]bool)
+	for i := range lines {
+		headers[i] = isFileHeader(i)
+	}
+	for i, l := range lines {
+		switch {
+		case headers[i]:
+			lines[i] = headerStyle.Render(l)
+		case strings.HasPrefix(l, "+"), strings.HasPrefix(l, "  added"):
+			lines[i] = addedStyle.Render(l)
+		case strings.HasPrefix(l, "-"), strings.HasPrefix(l, "  removed"):
+			lines[i] = removedStyle.Render(l)
+		case strings.HasPrefix(l, "@@"):
+			lines[i] = keywordStyle.Render(l)
+		default:
+			lines[i] = choiceStyle.Render(l)
+		}
+	}
+	return m.openPager("diff", lines)
+}
+
+func (m model) openHistoryView() model {
+	entries, err := readHistory(GetTgkDir())
+	if err != nil {
+		return m.openPager("history", []string{removedStyle.Render(err.Error())})
+	}
+	if len(entries) == 0 {
+		return m.openPager("history", []string{choiceStyle.Render("No swaps recorded yet.")})
+	}
+	// newest first, that is what one is looking for when something just broke
+	lines := make([]string, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Result == RESULT_SUCCESS {
+			lines = append(lines, choiceStyle.Render(entries[i].String()))
+		} else {
+			lines = append(lines, removedStyle.Render(entries[i].String()))
+		}
+	}
+	return m.openPager("history", lines)
+}
+
+// openLogView shows the end of the log file, newest record at the bottom like tail does.
+func (m model) openLogView() model {
+	lines, err := logTail(LOG_TAIL_LINES)
+	if err != nil {
+		return m.openPager("log", []string{removedStyle.Render(err.Error())})
+	}
+	for i, l := range lines {
+		if strings.Contains(l, " ERROR ") {
+			lines[i] = removedStyle.Render(l)
+		} else {
+			lines[i] = choiceStyle.Render(l)
+		}
+	}
+	m = m.openPager("log", lines)
+	if len(lines) > pagerPageSize {
+		m.pagerOffset = len(lines) - pagerPageSize
+	}
+	return m
+}
+
+// openTrashView lists the trash, most recently deleted first. The entry on top of the page is the one restored.
+func (m model) openTrashView() model {
+	entries := readTrash(GetTgkDir())
+	if len(entries) == 0 {
+		// a plain pager, there is nothing to restore
+		return m.openPager("empty trash", []string{choiceStyle.Render("The trash is empty.")})
+	}
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, choiceStyle.Render(e.String()))
+	}
+	return m.openPager("trash", lines)
+}
+
+// openThemePicker lists the themes with a swatch of their colors. The theme on top of the page is previewed while
+// scrolling and kept on enter.
+func (m model) openThemePicker() model {
+	themes, errs := tuiAssets.LoadThemes(themesDirPath())
+	m.themes = themes
+	lines := make([]string, 0, len(themes))
+	current := 0
+	for i, t := range themes {
+		swatch := ""
+		for _, r := range t.Roles() {
+			if r[1] != "" {
+				swatch += lipgloss.NewStyle().Background(lipgloss.Color(r[1])).Render("  ")
+			}
+		}
+		origin := "built-in"
+		if t.File != "" {
+			origin = filepath.Base(t.File)
+		}
+		lines = append(lines, fmt.Sprintf("%-16s %s  %s", t.Name, swatch, headerStyle.Render(origin)))
+		if t.Name == theme.Name {
+			current = i
+		}
+	}
+	m = m.openPager("themes", lines)
+	m.pagerOffset = current
+	// not listed, every line of the picker is a theme that can be picked
+	skipped := make([]string, 0, len(errs))
+	for _, err := range errs {
+		slog.Warn("theme skipped", "error", err)
+		skipped = append(skipped, "Skipped "+err.Error())
+	}
+	m.message = strings.Join(skipped, "\n")
+	return m
+}
+
+// previewTheme shows the theme on top of the theme picker without saving it.
+func (m model) previewTheme() {
+	if m.pager == "themes" && m.pagerOffset < len(m.themes) {
+		applyTheme(m.themes[m.pagerOffset])
+	}
+}
+
+// openPager shows lines, which are already styled, instead of the list until esc is pressed.
+func (m model) openPager(kind string, lines []string) model {
+	m.pager = kind
+	m.pagerLines = lines
+	m.pagerOffset = 0
+	return m
+}
+
+func (m model) updatePager(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	switch m.keys.action(KEYMAP_MODE_PAGER, msg.String()) {
+	case ACTION_HELP:
+		m.help = KEYMAP_MODE_PAGER
+	case ACTION_BACK:
+		if m.pager == "themes" {
+			// nothing was picked, undo the preview
+			restoreTheme(getTuiSettings(SETTINGS_FILE_NAME))
+		}
+		m.pagerLines = nil
+	case ACTION_APPLY:
+		if m.pager != "themes" || m.pagerOffset >= len(m.themes) {
+			break
+		}
+		pickTheme(m.themes[m.pagerOffset])
+		m.pagerLines = nil
+	case ACTION_UP:
+		if m.pagerOffset > 0 {
+			m.pagerOffset--
+		}
+		m.previewTheme()
+	case ACTION_DOWN:
+		// in the trash and the theme picker every entry has to be able to get on top
+		maxOffset := len(m.pagerLines) - pagerPageSize
+		if m.pager == "trash" || m.pager == "themes" {
+			maxOffset = len(m.pagerLines) - 1
+		}
+		if m.pagerOffset < maxOffset {
+			m.pagerOffset++
+		}
+		m.previewTheme()
+	case ACTION_RESTORE:
+		entries := readTrash(GetTgkDir())
+		if m.pager != "trash" || m.pagerOffset >= len(entries) {
+			break
+		}
+		_, err := restoreClient(GetCompleteSettings(SETTINGS_FILE_NAME), entries[m.pagerOffset].ID)
+		m = m.UpdateChoices().(model).openTrashView()
+		if err != nil {
+			m.pagerLines = append([]string{removedStyle.Render("Restore failed: " + err.Error()), ""}, m.pagerLines...)
+		}
+	case ACTION_UNDO:
+		if m.pager != "history" {
+			break
+		}
+		err := UndoLastSwap()
+		m = m.UpdateChoices().(model).openHistoryView()
+		if err != nil {
+			m.pagerLines = append([]string{removedStyle.Render("Undo failed: " + err.Error()), ""}, m.pagerLines...)
+		}
+	}
+	return m, nil
+}
+
+func (m model) pagerView() string {
+	end := m.pagerOffset + pagerPageSize
+	if end > len(m.pagerLines) {
+		end = len(m.pagerLines)
+	}
+	s := strings.Join(m.pagerLines[m.pagerOffset:end], "\n") + "\n"
+	if m.pager == "trash" || m.pager == "themes" {
+		s = cursorStyle.Render(cursorSymbol) + " " + strings.Join(m.pagerLines[m.pagerOffset:end], "\n  ") + "\n"
+	}
+	if m.message != "" {
+		s += removedStyle.Render(m.message) + "\n"
+	}
+	s += "\n" + drawFooter(m.keys.footer(KEYMAP_MODE_PAGER, m.pager), 2)
+	return drawInBox(s, activeBox, m.width) + "\n"
+}
+
+// helpView lists every key binding of the mode the overlay was opened from.
+func (m model) helpView() string {
+	s := headerStyle.Render("Keys") + "\n\n"
+	for _, l := range m.keys.help(m.help) {
+		s += choiceStyle.Render(l) + "\n"
+	}
+	if m.help == KEYMAP_MODE_LIST {
+		s += "\n" + headerStyle.Render("Markers") + "\n\n"
+		s += choiceStyle.Render(cursorSymbol+" "+leftbracketSymbol+" "+rightbracketSymbol+" entry under the cursor") + "\n"
+		s += choiceStyle.Render("  "+leftbracketSymbol+checkmarkSymbol+rightbracketSymbol+" selected entry") + "\n"
+	}
+	s += "\n" + drawFooter(helpFooterItems, 1)
+	return drawInBox(s, activeBox, m.width) + "\n"
+}
+
+const (
+	// confirms a swap and asks for the name the active client is saved under
+	PROMPT_OUTGOING_NAME = "outgoing name"
+	PROMPT_RENAME        = "rename"
+	PROMPT_DUPLICATE     = "duplicate"
+	// prefilled with the client name, so enter confirms
+	PROMPT_DELETE = "delete"
+)
+
+// visibleChoices returns the entries the list shows: the matches while filtering, every choice otherwise.
+func (m model) visibleChoices() []choiceMatch {
+	if m.filtering {
+		return m.matches
+	}
+	all := make([]choiceMatch, len(m.choices))
+	for i := range m.choices {
+		all[i] = choiceMatch{index: i}
+	}
+	return all
+}
+
+// applyFilter matches every choice against the filter and puts the cursor on the best match.
+func (m model) applyFilter() model {
+	m = m.matchFilter()
+	if len(m.matches) > 0 {
+		m.cursor = m.matches[0].index
+	}
+	return m
+}
+
+// matchFilter matches every choice against the filter, best matches first.
+func (m model) matchFilter() model {
+	if m.infos == nil {
+		m.infos = loadClientInfos(GetTgkDir(), m.choices)
+	}
+	pattern := string(m.filter)
+	m.matches = make([]choiceMatch, 0)
+	for i, choice := range m.choices {
+		score, positions, ok := utils.FuzzyMatch(pattern, choice)
+		best := choiceMatch{index: i, score: score, positions: positions}
+		// the name wins ties, it is what is shown anyway
+		for _, f := range m.infos[choice].searchFields() {
+			fieldScore, fieldPositions, fieldOk := utils.FuzzyMatch(pattern, f[1])
+			if fieldOk && (!ok || fieldScore > best.score) {
+				ok = true
+				best = choiceMatch{index: i, score: fieldScore, field: f[0], value: f[1], positions: fieldPositions}
+			}
+		}
+		if ok {
+			m.matches = append(m.matches, best)
+		}
+	}
+	sort.SliceStable(m.matches, func(a, b int) bool { return m.matches[a].score > m.matches[b].score })
+	return m
+}
+
+func (m model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// position of the cursor among the matches
+	current := 0
+	for i, match := range m.matches {
+		if match.index == m.cursor {
+			current = i
+		}
+	}
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.filtering = false
+		m.filter = nil
+		m.matches = nil
+	case tea.KeyUp:
+		if current > 0 {
+			m.cursor = m.matches[current-1].index
+		}
+	case tea.KeyDown:
+		if current < len(m.matches)-1 {
+			m.cursor = m.matches[current+1].index
+		}
+	case tea.KeyPgUp:
+		m = m.moveCursor(-m.pageSize())
+	case tea.KeyPgDown:
+		m = m.moveCursor(m.pageSize())
+	case tea.KeyBackspace:
+		if len(m.filter) > 0 {
+			m.filter = m.filter[:len(m.filter)-1]
+			m = m.applyFilter()
+		}
+	case tea.KeySpace:
+		m.filter = append(m.filter, ' ')
+		m = m.applyFilter()
+	case tea.KeyRunes:
+		m.filter = append(m.filter, msg.Runes...)
+		m = m.applyFilter()
+	case tea.KeyEnter:
+		if len(m.matches) == 0 {
+			break
+		}
+		// select the match and go back to the full list, ready to swap
+		selected := m.cursor
+		m.selected = map[int]struct{}{selected: {}}
+		m.lastSelected = &selected
+		m.filtering = false
+		m.filter = nil
+		m.matches = nil
+	}
+	return m, nil
+}
+
+// highlight renders the runes of s at positions with hl and everything else with base.
+func highlight(s string, positions []int, base lipgloss.Style, hl lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(s)
+	}
+	marked := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		marked[p] = true
+	}
+	result := ""
+	for i, r := range []rune(s) {
+		if marked[i] {
+			result += hl.Render(string(r))
+		} else {
+			result += base.Render(string(r))
+		}
+	}
+	return result
+}
+
+// openPrompt asks for a line of text about target, value is the editable default.
+func (m model) openPrompt(kind string, label string, value string, target string) model {
+	m.prompt = kind
+	m.promptLabel = label
+	m.promptInput = []rune(value)
+	m.promptTarget = target
+	return m
+}
+
+func (m model) closePrompt() model {
+	m.prompt = ""
+	m.promptInput = nil
+	m.promptTarget = ""
+	return m
+}
+
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		return m.closePrompt(), nil
+	case tea.KeyBackspace:
+		if len(m.promptInput) > 0 {
+			m.promptInput = m.promptInput[:len(m.promptInput)-1]
+		}
+	case tea.KeySpace:
+		m.promptInput = append(m.promptInput, ' ')
+	case tea.KeyRunes:
+		m.promptInput = append(m.promptInput, msg.Runes...)
+	case tea.KeyEnter:
+		return m.submitPrompt()
+	}
+	return m, nil
+}
+
+// submitPrompt acts on the entered text; on invalid input the prompt stays open with the reason shown above it.
+func (m model) submitPrompt() (tea.Model, tea.Cmd) {
+	value := strings.TrimSpace(string(m.promptInput))
+	if err := validClientName(value); err != nil {
+		m.message = err.Error()
+		return m, nil
+	}
+	switch m.prompt {
+	case PROMPT_OUTGOING_NAME:
+		err := m.swapFolders(value)
+		// nothing was touched, let the user pick another name
+		var collision *collisionError
+		if errors.As(err, &collision) {
+			m.message = value + " already exists."
+			m.promptInput = []rune(collision.Suggestion)
+			return m, nil
+		}
+		m = m.closePrompt().UpdateChoices().(model)
+		if err != nil {
+			m.message = err.Error()
+		}
+	case PROMPT_RENAME, PROMPT_DUPLICATE, PROMPT_DELETE:
+		set := GetCompleteSettings(SETTINGS_FILE_NAME)
+		var err error
+		switch m.prompt {
+		case PROMPT_RENAME:
+			err = renameClient(set, SETTINGS_FILE_NAME, m.promptTarget, value)
+		case PROMPT_DUPLICATE:
+			err = duplicateClient(set, m.promptTarget, value)
+		case PROMPT_DELETE:
+			if value != m.promptTarget {
+				m.message = "Enter " + m.promptTarget + " to confirm."
+				return m, nil
+			}
+			_, err = deleteClient(set, SETTINGS_FILE_NAME, m.promptTarget)
+		}
+		// a name that is taken can be corrected right away
+		if err != nil && m.prompt != PROMPT_DELETE {
+			m.message = err.Error()
+			return m, nil
+		}
+		m = m.closePrompt().UpdateChoices().(model)
+		if err != nil {
+			m.message = err.Error()
+		}
+	}
+	return m, nil
+}
+
+// drawFooter lays out the footer items in a grid in the footer color.
+func drawFooter(items []string, numRows int) string {
+	return footerStyle.Render(drawInGrid(items, numRows))
+}
+
 func drawInGrid(items []string, numRows int) string {
-	padding := " "
+	padding := ' '
 	// split items into n slices, where n is number of rows
 	// use ceiling division to ensure we always have enough room for all items
 	itemsPerRow := (len(items) + numRows - 1) / numRows
@@ -184,25 +1180,22 @@ func drawInGrid(items []string, numRows int) string {
 		rows[i] = make([]string, itemsPerRow)
 	}
 	counter := 0
-	maxLength := 0
+	maxWidth := 0
 	// Split items into rows and items per row
-	// since we are already iterating over the slice, we find the maximum length among items at the same time
+	// since we are already iterating over the slice, we find the widest item at the same time
 	for idx, item := range items {
 		rows[counter][idx%itemsPerRow] = item
 		if idx%itemsPerRow == itemsPerRow-1 {
 			counter += 1
 		}
-		if len(item) > maxLength {
-			maxLength = len(item)
-		}
+		maxWidth = max(maxWidth, utils.DisplayWidth(item))
 	}
 	s := ""
 	for rowNum, row := range rows {
 		for idx, item := range row {
+			s += utils.PadRight(item, padding, maxWidth)
 			if idx != itemsPerRow-1 {
-				s += item + strings.Repeat(padding, maxLength-len(item)) + strings.Repeat(padding, 2)
-			} else {
-				s += item + strings.Repeat(padding, maxLength-len(item))
+				s += strings.Repeat(string(padding), 2)
 			}
 		}
 		if rowNum != numRows-1 {
@@ -212,72 +1205,194 @@ func drawInGrid(items []string, numRows int) string {
 	return s
 }
 
-func drawInBox(s string, b tuiAssets.Box) string {
+// drawInBox draws the box b around s. All widths are in terminal cells, so umlauts, CJK and styled text line up.
+// Lines that would make the box wider than maxWidth, usually the width of the terminal, end in an ellipsis; 0 means
+// no limit.
+func drawInBox(s string, b tuiAssets.Box, maxWidth int) string {
 	padRune := ' '
-	numRunesLeftBar := utf8.RuneCountInString(b.LeftBar)
-	numRunesRightBar := utf8.RuneCountInString(b.RightBar)
 	// num of spaces to add between borders and content; vertical: in rows, horizontal: in spaces
-	verticalPaddingCount := 1 // vertical padding currently isnt clean as the box characters at the sides are missing!
+	verticalPaddingCount := 1
 	horizontalPaddingCount := 4
 	horizontalPadding := strings.Repeat(string(padRune), horizontalPaddingCount)
-	// split string into slice to find longest row
 	ss := strings.Split(s, "\n")
-	// loop over all rows, add padding and find longest row
-	maxLineLength := 0
+	contentWidth := 0
 	for _, l := range ss {
-		// get hypothetical len to not change the line just now >> needs padding based on max len
-		// len of line string + 2 for box chars + twice the horizontalPadding
-		lineLength := len(utils.StripANSI(l)) + numRunesLeftBar + numRunesRightBar + horizontalPaddingCount*2
-		if lineLength > maxLineLength {
-			maxLineLength = lineLength
-		}
+		contentWidth = max(contentWidth, utils.DisplayWidth(l))
+	}
+	// everything around the content
+	frameWidth := utils.DisplayWidth(b.LeftBar) + utils.DisplayWidth(b.RightBar) + horizontalPaddingCount*2
+	if maxWidth > 0 && contentWidth+frameWidth > maxWidth {
+		contentWidth = max(maxWidth-frameWidth, 1)
 	}
-	// need a second loop in order to apply padding based on maxLineLength!
-	// we strip ANSI escape sequences from the line because those interfere with the padding.
-	padded_ss := []string{}
+	totalWidth := contentWidth + frameWidth
+	paddedLines := make([]string, 0, len(ss))
 	for _, l := range ss {
-		l = boxStyle.Render(b.LeftBar) + horizontalPadding + l
-		// the padding does not work cleanly if we swap out LeftBar for p.e. "x" instead of "\u2551"
-		l = l + utils.PadRight("", padRune, maxLineLength-len(utils.StripANSI(l))-horizontalPaddingCount+numRunesLeftBar) + horizontalPadding + boxStyle.Render(b.RightBar)
-		padded_ss = append(padded_ss, l)
-	}
-	topLine := boxStyle.Render(b.TopLeftCorner)
-	topLine += strings.Repeat(boxStyle.Render(b.TopBar), maxLineLength-numRunesLeftBar-numRunesRightBar)
-	topLine += boxStyle.Render(b.TopRightCorner)
-	bottomLine := boxStyle.Render(b.BottomLeftCorner)
-	bottomLine += strings.Repeat(boxStyle.Render(b.BottomBar), maxLineLength-numRunesLeftBar-numRunesRightBar)
-	bottomLine += boxStyle.Render(b.BottomRightCorner)
-	verticalPad := boxStyle.Render(b.LeftBar) + strings.Repeat(" ", maxLineLength-numRunesLeftBar-numRunesRightBar) + boxStyle.Render(b.RightBar) + "\n"
+		l = utils.PadRight(utils.Truncate(l, contentWidth), padRune, contentWidth)
+		paddedLines = append(paddedLines, boxStyle.Render(b.LeftBar)+horizontalPadding+l+horizontalPadding+boxStyle.Render(b.RightBar))
+	}
+	topLine := boxStyle.Render(b.TopLeftCorner + fillBar(b.TopBar, totalWidth-utils.DisplayWidth(b.TopLeftCorner)-utils.DisplayWidth(b.TopRightCorner)) + b.TopRightCorner)
+	bottomLine := boxStyle.Render(b.BottomLeftCorner + fillBar(b.BottomBar, totalWidth-utils.DisplayWidth(b.BottomLeftCorner)-utils.DisplayWidth(b.BottomRightCorner)) + b.BottomRightCorner)
+	verticalPad := boxStyle.Render(b.LeftBar) + strings.Repeat(string(padRune), totalWidth-utils.DisplayWidth(b.LeftBar)-utils.DisplayWidth(b.RightBar)) + boxStyle.Render(b.RightBar) + "\n"
 	verticalPadding := strings.Repeat(verticalPad, verticalPaddingCount)
 	ret := topLine + "\n" +
 		verticalPadding +
-		strings.Join(padded_ss[:], "\n") + "\n" +
+		strings.Join(paddedLines, "\n") + "\n" +
 		verticalPadding +
 		bottomLine
 	return ret
 }
 
-func changeColors() {
-	newColor := tuiAssets.GetColorIterator().Next()
-	updateTextStyleColor(&keywordStyle, newColor)
-	updateTextStyleColor(&boxStyle, newColor)
-	updateTextStyleColor(&cursorStyle, newColor)
+// fillBar repeats bar over width cells; if bar is wider than one cell the rest is filled with spaces.
+func fillBar(bar string, width int) string {
+	barWidth := max(utils.DisplayWidth(bar), 1)
+	if width <= 0 {
+		return ""
+	}
+	return utils.PadRight(strings.Repeat(bar, width/barWidth), ' ', width)
+}
+
+// changeColors moves to the next or previous color and remembers it for the next start.
+func changeColors(forward bool) {
+	var newColor string
+	if forward {
+		newColor = tuiAssets.GetColorIterator().Next()
+	} else {
+		newColor = tuiAssets.GetColorIterator().Previous()
+	}
+	applyColor(newColor)
+	setTuiSettings(SETTINGS_FILE_NAME, "Color", newColor)
+}
+
+// applyTheme colors every role of the TUI after t.
+func applyTheme(t tuiAssets.Theme) {
+	theme = t
+	choiceStyle = choiceStyle.Foreground(lipgloss.Color(t.Choices))
+	footerStyle = footerStyle.Foreground(lipgloss.Color(t.Footer))
+	headerStyle = headerStyle.Foreground(lipgloss.Color(t.Header))
+	selectionStyle = selectionStyle.Background(lipgloss.Color(t.Selection))
+	addedStyle = addedStyle.Foreground(lipgloss.Color(t.Success))
+	removedStyle = removedStyle.Foreground(lipgloss.Color(t.Error))
+	applyColor(t.Accent)
 }
 
-func changeBoxStyle() {
-	activeBox = tuiAssets.GetBoxIterator().Next()
+// pickTheme applies t and remembers it for the next start. The accent color chosen with changeColors belonged to the
+// previous theme and is dropped.
+func pickTheme(t tuiAssets.Theme) {
+	applyTheme(t)
+	tuiAssets.GetColorIterator().Set(t.Accent)
+	setTuiSettings(SETTINGS_FILE_NAME, "Theme", t.Name)
+	setTuiSettings(SETTINGS_FILE_NAME, "Color", "")
+}
+
+const THEMES_DIR_NAME = "themes"
+
+// themesDirPath returns the directory with the themes of one's own, next to the settings file.
+func themesDirPath() string {
+	return filepath.Join(filepath.Dir(settingsFilePath()), THEMES_DIR_NAME)
+}
+
+func applyColor(color string) {
+	updateTextStyleColor(&keywordStyle, color)
+	updateTextStyleColor(&boxStyle, color)
+	updateTextStyleColor(&cursorStyle, color)
+}
+
+// changeBoxStyle draws the next or previous box and keeps it for the next start. Plain mode sticks to the ASCII box.
+func changeBoxStyle(forward bool) error {
+	if plainMode {
+		return errors.New("Plain mode always draws the ASCII box.")
+	}
+	if forward {
+		activeBox = tuiAssets.GetBoxIterator().Next()
+	} else {
+		activeBox = tuiAssets.GetBoxIterator().Previous()
+	}
+	setTuiSettings(SETTINGS_FILE_NAME, "Box", tuiAssets.GetBoxIterator().Name())
+	return nil
+}
+
+// registerBoxes adds the boxes of one's own from the settings to the ones changeBoxStyle cycles through, in the order
+// of their names. A box that cannot be drawn is left out.
+func registerBoxes(boxes map[string]tuiAssets.Box) []error {
+	names := make([]string, 0, len(boxes))
+	for name := range boxes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]error, 0)
+	for _, name := range names {
+		err := tuiAssets.GetBoxIterator().Register(name, boxes[name])
+		if err != nil {
+			slog.Warn("box skipped", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// restoreTheme brings back the theme, color and box chosen last time; a choice that no longer exists falls back to the
+// default.
+func restoreTheme(tuiSettings TuiSettings) {
+	applyTheme(tuiAssets.GetDefaultTheme())
+	if tuiSettings.Theme != "" {
+		themes, _ := tuiAssets.LoadThemes(themesDirPath())
+		if i := tuiAssets.FindTheme(themes, tuiSettings.Theme); i >= 0 {
+			applyTheme(themes[i])
+		} else {
+			slog.Warn("unknown theme in the settings, using the default", "theme", tuiSettings.Theme)
+		}
+	}
+	if tuiSettings.Color != "" {
+		if tuiAssets.GetColorIterator().Set(tuiSettings.Color) {
+			applyColor(tuiAssets.GetColorIterator().Current())
+		} else {
+			slog.Warn("unknown color in the settings, using the default", "color", tuiSettings.Color)
+		}
+	}
+	if tuiSettings.Box != "" {
+		if box, ok := tuiAssets.GetBoxIterator().Set(tuiSettings.Box); ok {
+			activeBox = box
+		} else {
+			slog.Warn("unknown box in the settings, using the default", "box", tuiSettings.Box)
+		}
+	}
+	if plainMode {
+		activeBox = tuiAssets.Ascii()
+	}
 }
 
 func updateTextStyleColor(toUpdate *lipgloss.Style, newColor string) {
 	*toUpdate = toUpdate.Foreground(lipgloss.Color(newColor))
 }
 
-func runTui() {
-	dirsWithOutTgkFolder := DirectoriesInTgkDirExcludingTgkFolder()
+// runTui shows the TUI until it is quit. Its errors are returned so that the watcher is stopped before the program
+// exits.
+func runTui(plain bool) error {
+	// the boxes and theme live in tuiAssets for the whole program, they are set up once and not with every model
+	tuiSettings := getTuiSettings(SETTINGS_FILE_NAME)
+	warnings := make([]string, 0)
+	for _, err := range registerBoxes(tuiSettings.Boxes) {
+		warnings = append(warnings, "Box in the settings ignored: "+err.Error())
+	}
+	if plain {
+		usePlainMode()
+	}
+	restoreTheme(tuiSettings)
+	dirsWithOutTgkFolder, err := DirectoriesInTgkDirExcludingTgkFolder()
+	if err != nil {
+		return err
+	}
 	activeVersion := GetActiveVersion()
-	p := tea.NewProgram(mainModel(dirsWithOutTgkFolder, activeVersion))
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Something went wrong: %s", err)
-		os.Exit(1)
+	m := mainModel(dirsWithOutTgkFolder, activeVersion, warnings)
+	p := tea.NewProgram(m)
+	// pick up clients dropped into Tgkdir, p.e. by a colleague through a network share, while the TUI is open
+	tgkDir := GetTgkDir()
+	watched := []string{tgkDir}
+	if utils.Exists(manifestsDirPath(tgkDir)) {
+		watched = append(watched, manifestsDirPath(tgkDir))
 	}
+	stopWatching := utils.WatchDirs(watched, watchPollInterval, func() { p.Send(tgkDirChangedMsg{}) })
+	defer stopWatching()
+	_, err = p.Run()
+	return err
 }
@@ -1,6 +1,9 @@
 package tuiAssets
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
 
 const (
 	ORANGE    string = "#ed832d"
@@ -61,3 +64,19 @@ func (ci *ColorIterator) Previous() string {
 	color := ci.colors[ci.index]
 	return color
 }
+
+// Current returns the current color.
+func (ci *ColorIterator) Current() string {
+	return ci.colors[ci.index]
+}
+
+// Set makes color the current one, ok is false if it is not one of the available colors.
+func (ci *ColorIterator) Set(color string) bool {
+	for i, c := range ci.colors {
+		if strings.EqualFold(c, color) {
+			ci.index = i
+			return true
+		}
+	}
+	return false
+}
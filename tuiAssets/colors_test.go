@@ -0,0 +1,32 @@
+package tuiAssets
+
+import "testing"
+
+func Test_ColorIterator_Set(t *testing.T) {
+	ci := newColorIterator()
+	for _, color := range availableColors {
+		if !ci.Set(color) {
+			t.Errorf("Could not set available color %s", color)
+		}
+		if ci.Current() != color {
+			t.Errorf("Set %s, current is %s", color, ci.Current())
+		}
+	}
+	// settings written by hand may use capitals
+	if !ci.Set("#ED832D") || ci.Current() != ORANGE {
+		t.Errorf("Color in capitals was not found, current is %s", ci.Current())
+	}
+	if ci.Set("#123456") || ci.Current() != ORANGE {
+		t.Errorf("Unknown color was set or changed the current one to %s", ci.Current())
+	}
+}
+
+func Test_ColorIterator_wraps(t *testing.T) {
+	ci := newColorIterator()
+	if got := ci.Previous(); got != availableColors[len(availableColors)-1] {
+		t.Errorf("Previous of the first color is %s, want the last one", got)
+	}
+	if got := ci.Next(); got != availableColors[0] {
+		t.Errorf("Next of the last color is %s, want the first one", got)
+	}
+}
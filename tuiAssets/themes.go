@@ -0,0 +1,145 @@
+package tuiAssets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A theme gives a color to every role of the TUI. Themes are JSON files in a themes directory, one per file:
+//
+//	{"name": "dusk", "accent": "#cf02f7", "header": "#807d7d", "footer": "#807d7d", "choices": "#ffffff",
+//	 "error": "#fc0303", "success": "#0be04b", "selection": "#333333"}
+//
+// The name defaults to the file name. A role left out keeps the color of the default theme, selection is the
+// background of the entry under the cursor and may stay empty for none. Colors are hex or ANSI numbers 0-255.
+
+type Theme struct {
+	Name      string `json:"name"`
+	Accent    string `json:"accent"`
+	Header    string `json:"header"`
+	Footer    string `json:"footer"`
+	Choices   string `json:"choices"`
+	Error     string `json:"error"`
+	Success   string `json:"success"`
+	Selection string `json:"selection"`
+	// empty for the built-in themes
+	File string `json:"-"`
+}
+
+var hexColorRegexp = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// names of availableColors, in the same order, each one is a built-in theme
+var availableColorNames = []string{"orange", "red", "rose", "hotpink", "lilac", "purple", "lightblue", "darkblue", "yellow", "lime", "green", "darkgreen", "white", "grey"}
+
+// BuiltinThemes returns a theme for each of the available colors, the first one is the default theme.
+func BuiltinThemes() []Theme {
+	themes := make([]Theme, len(availableColors))
+	for i, color := range availableColors {
+		themes[i] = Theme{
+			Name:    availableColorNames[i],
+			Accent:  color,
+			Header:  GREY,
+			Footer:  GREY,
+			Choices: WHITE,
+			Error:   RED,
+			Success: GREEN,
+		}
+	}
+	return themes
+}
+
+func GetDefaultTheme() Theme {
+	return BuiltinThemes()[0]
+}
+
+// validColor tells whether lipgloss understands c as a color.
+func validColor(c string) bool {
+	if hexColorRegexp.MatchString(c) {
+		return true
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// Roles returns the colors of t as role/color pairs.
+func (t Theme) Roles() [][2]string {
+	return [][2]string{
+		{"accent", t.Accent},
+		{"header", t.Header},
+		{"footer", t.Footer},
+		{"choices", t.Choices},
+		{"error", t.Error},
+		{"success", t.Success},
+		{"selection", t.Selection},
+	}
+}
+
+// Validate checks that every role has a color lipgloss understands; only selection may be empty.
+func (t Theme) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("theme has no name")
+	}
+	for _, r := range t.Roles() {
+		if r[1] == "" && r[0] == "selection" {
+			continue
+		}
+		if !validColor(r[1]) {
+			return fmt.Errorf("theme %s: %q is not a color for %s", t.Name, r[1], r[0])
+		}
+	}
+	return nil
+}
+
+// readTheme reads a theme file, roles it leaves out are taken from the default theme.
+func readTheme(path string) (Theme, error) {
+	t := GetDefaultTheme()
+	t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	t.File = path
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(b, &t)
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, t.Validate()
+}
+
+// LoadThemes returns the built-in themes followed by the ones in dir, in the order of their file names. A theme file
+// that cannot be used is left out and reported in errs; a missing dir just means there are no themes of one's own.
+func LoadThemes(dir string) (themes []Theme, errs []error) {
+	themes = BuiltinThemes()
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		t, err := readTheme(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// a file may replace a built-in theme of the same name
+		if i := FindTheme(themes, t.Name); i >= 0 {
+			themes[i] = t
+			continue
+		}
+		themes = append(themes, t)
+	}
+	return themes, errs
+}
+
+// FindTheme returns the index of the theme called name, -1 if there is none.
+func FindTheme(themes []Theme, name string) int {
+	for i, t := range themes {
+		if strings.EqualFold(t.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
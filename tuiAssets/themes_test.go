@@ -0,0 +1,104 @@
+package tuiAssets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTheme(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Theme_Validate(t *testing.T) {
+	valid := GetDefaultTheme()
+	tests := []struct {
+		name    string
+		change  func(*Theme)
+		wantErr bool
+	}{
+		{"default", func(*Theme) {}, false},
+		{"short hex", func(t *Theme) { t.Accent = "#f0a" }, false},
+		{"ansi number", func(t *Theme) { t.Accent = "212" }, false},
+		{"selection may stay empty", func(t *Theme) { t.Selection = "" }, false},
+		{"no name", func(t *Theme) { t.Name = " " }, true},
+		{"color name", func(t *Theme) { t.Accent = "red" }, true},
+		{"hex without #", func(t *Theme) { t.Header = "ed832d" }, true},
+		{"ansi out of range", func(t *Theme) { t.Footer = "256" }, true},
+		{"empty role", func(t *Theme) { t.Error = "" }, true},
+	}
+	for _, tt := range tests {
+		theme := valid
+		tt.change(&theme)
+		if err := theme.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want an error: %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_readTheme(t *testing.T) {
+	dir := t.TempDir()
+	// the name comes from the file, left out roles from the default theme
+	theme, err := readTheme(writeTheme(t, dir, "dusk.json", `{"accent": "#cf02f7", "selection": "#333333"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GetDefaultTheme()
+	want.Name, want.Accent, want.Selection, want.File = "dusk", "#cf02f7", "#333333", filepath.Join(dir, "dusk.json")
+	if theme != want {
+		t.Errorf("Got %+v, want %+v", theme, want)
+	}
+	theme, err = readTheme(writeTheme(t, dir, "file.json", `{"name": "night"}`))
+	if err != nil || theme.Name != "night" {
+		t.Errorf("Got %q, %v, want the name from the file content", theme.Name, err)
+	}
+
+	if _, err := readTheme(writeTheme(t, dir, "bad.json", `{"accent": "purple"}`)); err == nil || !strings.Contains(err.Error(), "accent") {
+		t.Errorf("Got %v, want an error about the accent", err)
+	}
+	if _, err := readTheme(writeTheme(t, dir, "broken.json", `{"accent": `)); err == nil || !strings.Contains(err.Error(), "broken.json") {
+		t.Errorf("Got %v, want an error naming the file", err)
+	}
+	if _, err := readTheme(filepath.Join(dir, "gone.json")); err == nil {
+		t.Errorf("Missing file was read")
+	}
+}
+
+func Test_LoadThemes(t *testing.T) {
+	builtin := BuiltinThemes()
+	themes, errs := LoadThemes(filepath.Join(t.TempDir(), "gone"))
+	if len(themes) != len(builtin) || len(errs) != 0 {
+		t.Errorf("Missing dir gave %d themes and %v, want only the built-in ones", len(themes), errs)
+	}
+
+	dir := t.TempDir()
+	writeTheme(t, dir, "b.json", `{"accent": "#111111"}`)
+	writeTheme(t, dir, "a.json", `{"accent": "#222222"}`)
+	writeTheme(t, dir, "red.json", `{"accent": "#333333"}`)
+	writeTheme(t, dir, "bad.json", `{"accent": "nope"}`)
+	writeTheme(t, dir, "notes.txt", `not a theme`)
+	themes, errs = LoadThemes(dir)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "nope") {
+		t.Errorf("Got %v, want the error of bad.json", errs)
+	}
+	if len(themes) != len(builtin)+2 {
+		t.Fatalf("Got %d themes, want the built-in ones, a and b", len(themes))
+	}
+	if themes[len(builtin)].Name != "a" || themes[len(builtin)+1].Name != "b" {
+		t.Errorf("Own themes are %s and %s, want them in the order of their files", themes[len(builtin)].Name, themes[len(builtin)+1].Name)
+	}
+	// a file named like a built-in theme replaces it in place
+	i := FindTheme(themes, "RED")
+	if i != FindTheme(builtin, "red") || themes[i].Accent != "#333333" || themes[i].File == "" {
+		t.Errorf("Built-in theme red was not overridden, got %+v at %d", themes[i], i)
+	}
+	if FindTheme(themes, "nope") != -1 {
+		t.Errorf("Found a theme that does not exist")
+	}
+}
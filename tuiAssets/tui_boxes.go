@@ -1,18 +1,24 @@
 package tuiAssets
 
 import (
+	"fmt"
+	"strings"
 	"sync"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
 )
 
+// Box holds the border segments of a box, the json names are the ones used for boxes of one's own in the settings.
 type Box struct {
-	TopLeftCorner     string
-	TopRightCorner    string
-	TopBar            string
-	BottomLeftCorner  string
-	BottomRightCorner string
-	BottomBar         string
-	LeftBar           string
-	RightBar          string
+	TopLeftCorner     string `json:"topleft"`
+	TopRightCorner    string `json:"topright"`
+	TopBar            string `json:"top"`
+	BottomLeftCorner  string `json:"bottomleft"`
+	BottomRightCorner string `json:"bottomright"`
+	BottomBar         string `json:"bottom"`
+	LeftBar           string `json:"left"`
+	RightBar          string `json:"right"`
 }
 
 func DoublePiped() Box {
@@ -93,20 +99,51 @@ func Crosses() Box {
 	}
 }
 
+// BoxConstructor returns a box; a constructor rather than a value so boxes can be built with more logic in the future.
+type BoxConstructor func() Box
+
+type namedBox struct {
+	Name string
+	New  BoxConstructor
+}
+
+// Ascii only uses characters every terminal and screen reader knows.
+func Ascii() Box {
+	return Box{
+		TopLeftCorner:     "+",
+		TopRightCorner:    "+",
+		BottomLeftCorner:  "+",
+		BottomRightCorner: "+",
+		TopBar:            "-",
+		BottomBar:         "-",
+		LeftBar:           "|",
+		RightBar:          "|",
+	}
+}
+
 var (
 	onceBoxes      sync.Once
 	boxIterator    *BoxIterator
-	availableBoxes = []interface{}{SingleRounded, DoublePiped, SinglePiped, Fat, FatDots, Crosses}
+	availableBoxes = []namedBox{
+		{"singlerounded", SingleRounded},
+		{"doublepiped", DoublePiped},
+		{"singlepiped", SinglePiped},
+		{"fat", Fat},
+		{"fatdots", FatDots},
+		{"crosses", Crosses},
+		{"ascii", Ascii},
+	}
 )
 
 type BoxIterator struct {
-	boxes []interface{}
+	boxes []namedBox
 	index int
 }
 
 func newBoxIterator() *BoxIterator {
 	return &BoxIterator{
-		boxes: availableBoxes,
+		// a copy, so registering a box does not change the defaults
+		boxes: append([]namedBox(nil), availableBoxes...),
 		index: 0,
 	}
 }
@@ -120,26 +157,67 @@ func GetBoxIterator() *BoxIterator {
 
 func (bi *BoxIterator) Next() Box {
 	bi.index = (bi.index + 1) % len(bi.boxes)
-	boxConstructor := bi.boxes[bi.index]
-	if f, ok := boxConstructor.(func() Box); ok {
-		return f()
-	}
-	return Box{}
+	return bi.boxes[bi.index].New()
 }
 
 func (bi *BoxIterator) Previous() Box {
 	bi.index = (bi.index - 1 + len(bi.boxes)) % len(bi.boxes)
-	boxConstructor := bi.boxes[bi.index]
-	if f, ok := boxConstructor.(func() Box); ok {
-		return f()
+	return bi.boxes[bi.index].New()
+}
+
+// Name returns the name of the current box.
+func (bi *BoxIterator) Name() string {
+	return bi.boxes[bi.index].Name
+}
+
+// Set makes the box called name the current one and returns it, ok is false if there is no such box.
+func (bi *BoxIterator) Set(name string) (Box, bool) {
+	for i, b := range bi.boxes {
+		if b.Name == name {
+			bi.index = i
+			return b.New(), true
+		}
 	}
-	return Box{}
+	return Box{}, false
 }
 
-func GetDefaultBox() Box {
-	// currently this could just be an indexing into availableBoxes[0], but if we change the box constructors in the future to include more logic, we want to get the constructors and run them.
-	if f, ok := newBoxIterator().boxes[0].(func() Box); ok {
-		return f()
+// Register adds box under name after the built-in boxes, if every border segment of it is a single cell wide.
+func (bi *BoxIterator) Register(name string, box Box) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("box has no name")
 	}
-	return Box{}
+	for _, b := range bi.boxes {
+		if b.Name == name {
+			return fmt.Errorf("there already is a box called %s", name)
+		}
+	}
+	err := box.Validate()
+	if err != nil {
+		return fmt.Errorf("box %s: %w", name, err)
+	}
+	bi.boxes = append(bi.boxes, namedBox{name, func() Box { return box }})
+	return nil
+}
+
+// Validate checks that every border segment is exactly one cell wide, drawing the box depends on it.
+func (b Box) Validate() error {
+	for _, segment := range [][2]string{
+		{"topleft", b.TopLeftCorner},
+		{"topright", b.TopRightCorner},
+		{"top", b.TopBar},
+		{"bottomleft", b.BottomLeftCorner},
+		{"bottomright", b.BottomRightCorner},
+		{"bottom", b.BottomBar},
+		{"left", b.LeftBar},
+		{"right", b.RightBar},
+	} {
+		if runewidth.StringWidth(segment[1]) != 1 || utf8.RuneCountInString(segment[1]) != 1 {
+			return fmt.Errorf("%s must be a single character one cell wide, got %q", segment[0], segment[1])
+		}
+	}
+	return nil
+}
+
+func GetDefaultBox() Box {
+	return availableBoxes[0].New()
 }
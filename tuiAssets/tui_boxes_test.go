@@ -0,0 +1,108 @@
+package tuiAssets
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// in a CJK locale the box characters would count two cells wide, the tests measure as in any other locale
+func TestMain(m *testing.M) {
+	runewidth.DefaultCondition.EastAsianWidth = false
+	os.Exit(m.Run())
+}
+
+func Test_BoxIterator_Set(t *testing.T) {
+	bi := newBoxIterator()
+	for _, named := range availableBoxes {
+		box, ok := bi.Set(named.Name)
+		if !ok {
+			t.Errorf("Could not set available box %s", named.Name)
+		}
+		if bi.Name() != named.Name {
+			t.Errorf("Set %s, current is %s", named.Name, bi.Name())
+		}
+		if !reflect.DeepEqual(box, named.New()) {
+			t.Errorf("Set %s returned another box", named.Name)
+		}
+	}
+	if _, ok := bi.Set("nope"); ok || bi.Name() != availableBoxes[len(availableBoxes)-1].Name {
+		t.Errorf("Unknown box was set or changed the current one to %s", bi.Name())
+	}
+}
+
+func Test_BoxIterator_wraps(t *testing.T) {
+	bi := newBoxIterator()
+	bi.Previous()
+	if bi.Name() != availableBoxes[len(availableBoxes)-1].Name {
+		t.Errorf("Previous of the first box is %s, want the last one", bi.Name())
+	}
+	bi.Next()
+	if bi.Name() != availableBoxes[0].Name {
+		t.Errorf("Next of the last box is %s, want the first one", bi.Name())
+	}
+}
+
+func Test_Box_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment string
+		wantErr bool
+	}{
+		{"ascii", "*", false},
+		{"empty", "", true},
+		{"two characters", "--", true},
+		{"wide character", "界", true},
+		{"combining accent", "e\u0301", true},
+		{"tab", "\t", true},
+	}
+	for _, tt := range tests {
+		box := Ascii()
+		box.TopBar = tt.segment
+		if err := box.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want an error: %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_builtinBoxes(t *testing.T) {
+	for _, named := range availableBoxes {
+		if err := named.New().Validate(); err != nil {
+			t.Errorf("Built-in box %s cannot be drawn: %s", named.Name, err)
+		}
+	}
+}
+
+func Test_BoxIterator_Register(t *testing.T) {
+	bi := newBoxIterator()
+	stars := Box{"*", "*", "*", "*", "*", "*", "*", "*"}
+	if err := bi.Register("stars", stars); err != nil {
+		t.Fatal(err)
+	}
+	if box, ok := bi.Set("stars"); !ok || box != stars {
+		t.Errorf("Registered box was not set, got %+v", box)
+	}
+	bi.Next()
+	if bi.Name() != availableBoxes[0].Name {
+		t.Errorf("Next of the registered box is %s, want it after the built-in ones", bi.Name())
+	}
+	for _, name := range []string{"stars", "ascii", " "} {
+		if err := bi.Register(name, stars); err == nil {
+			t.Errorf("Registered a second box called %q", name)
+		}
+	}
+	broken := stars
+	broken.LeftBar = "||"
+	if err := bi.Register("broken", broken); err == nil {
+		t.Errorf("Registered a box that cannot be drawn")
+	}
+	if _, ok := bi.Set("broken"); ok {
+		t.Errorf("Rejected box can be set")
+	}
+	// the defaults are not touched
+	if _, ok := newBoxIterator().Set("stars"); ok {
+		t.Errorf("Registering changed the built-in boxes")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+
+	"fastSwapper/tuiAssets"
+	"fastSwapper/utils"
+)
+
+// go test -run Test_drawIn -update rewrites the golden files after an intended change of the layout
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// The box characters are ambiguous in width: in a CJK locale runewidth counts them two cells wide, which would change
+// every layout and reject the built-in boxes. The tests measure as in any other locale.
+func TestMain(m *testing.M) {
+	runewidth.DefaultCondition.EastAsianWidth = false
+	os.Exit(m.Run())
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	// whether lipgloss colors anything depends on the terminal the tests run in
+	got = utils.StripANSI(got) + "\n"
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read golden file, run with -update to create it: %s", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s does not match %s.\nGot:\n%s\nWant:\n%s", name, path, got, want)
+	}
+}
+
+// checkBoxWidths makes sure every line of a drawn box is equally wide, which is what breaks first.
+func checkBoxWidths(t *testing.T, box string) {
+	t.Helper()
+	lines := strings.Split(box, "\n")
+	for i, l := range lines {
+		if utils.DisplayWidth(l) != utils.DisplayWidth(lines[0]) {
+			t.Errorf("Line %d is %d cells wide, the top line %d:\n%s", i, utils.DisplayWidth(l), utils.DisplayWidth(lines[0]), box)
+		}
+	}
+}
+
+func Test_drawInBox(t *testing.T) {
+	ascii := tuiAssets.Box{TopLeftCorner: "+", TopRightCorner: "+", TopBar: "-", BottomLeftCorner: "+", BottomRightCorner: "+", BottomBar: "-", LeftBar: "|", RightBar: "|"}
+	// border segments two cells wide, not allowed in the settings but what an ambiguous width glyph becomes in an east
+	// asian locale
+	wide := ascii
+	wide.TopBar = "\u2550\u2550"
+	wide.LeftBar = "\u2551 "
+	tests := []struct {
+		name     string
+		content  string
+		box      tuiAssets.Box
+		maxWidth int
+	}{
+		{"box_ascii", "Tagetik 5.2\nTagetik 5.3 Kunde", ascii, 0},
+		{"box_umlauts", "Müller GmbH\nÄrztekammer Süd\nGroß", tuiAssets.SingleRounded(), 0},
+		{"box_cjk", "客户端 5.2\nclient", tuiAssets.DoublePiped(), 0},
+		{"box_combining", "Cafe\u0301 Kunde\nMu\u0308ller", ascii, 0},
+		{"box_styled", keywordStyle.Render("Müller") + " " + choiceStyle.Render("[x]"), ascii, 0},
+		{"box_wide_border", "Müller\n客户", wide, 0},
+		{"box_truncated", "Müller Maschinenbau GmbH & Co. KG\n客户端客户端客户端\nshort", ascii, 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := drawInBox(tt.content, tt.box, tt.maxWidth)
+			checkBoxWidths(t, got)
+			if tt.maxWidth > 0 && utils.DisplayWidth(strings.Split(got, "\n")[0]) > tt.maxWidth {
+				t.Errorf("Box is wider than %d cells:\n%s", tt.maxWidth, got)
+			}
+			checkGolden(t, tt.name, got)
+		})
+	}
+}
+
+func Test_drawInGrid(t *testing.T) {
+	items := []string{"q: beenden", "ü: tauschen", "客: 客户", "d: diff", "s: sortieren"}
+	checkGolden(t, "grid_two_rows", drawInGrid(items, 2))
+	checkGolden(t, "grid_in_box", drawInBox(drawInGrid(items, 2), tuiAssets.SingleRounded(), 0))
+	checkBoxWidths(t, drawInBox(drawInGrid(items, 2), tuiAssets.SingleRounded(), 0))
+}
+
+func Test_listViewport(t *testing.T) {
+	keys, _ := loadKeymap(nil)
+	m := model{
+		selected:       make(map[int]struct{}),
+		sortMode:       SORT_NAME,
+		infos:          make(map[string]clientInfo),
+		details:        make(map[string]clientDetails),
+		detailsLoading: make(map[string]bool),
+		keys:           keys,
+	}
+	for i := 0; i < 60; i++ {
+		m.choices = append(m.choices, fmt.Sprintf("client %02d", i))
+	}
+	var tm tea.Model = m
+	tm, _ = tm.Update(tea.WindowSizeMsg{Width: 200, Height: 30})
+	press := func(k tea.KeyType) {
+		tm, _ = tm.Update(tea.KeyMsg{Type: k})
+	}
+	check := func(wantAbove bool, wantBelow bool) {
+		t.Helper()
+		view := tm.View()
+		if lines := strings.Count(view, "\n") + 1; lines > tm.(model).height {
+			t.Errorf("View is %d lines high in a terminal of %d:\n%s", lines, tm.(model).height, view)
+		}
+		cursor := tm.(model).choices[tm.(model).cursor]
+		if !strings.Contains(view, "> [ ] "+cursor) {
+			t.Errorf("The cursor on %s is out of view:\n%s", cursor, view)
+		}
+		if strings.Contains(view, "more above") != wantAbove || strings.Contains(view, "more below") != wantBelow {
+			t.Errorf("Wanted more above: %t, more below: %t:\n%s", wantAbove, wantBelow, view)
+		}
+	}
+	check(false, true)
+	press(tea.KeyPgDown)
+	check(true, true)
+	press(tea.KeyEnd)
+	check(true, false)
+	if got := tm.(model).cursor; got != 59 {
+		t.Errorf("End put the cursor on %d, want 59", got)
+	}
+	press(tea.KeyPgUp)
+	check(true, true)
+	press(tea.KeyHome)
+	check(false, true)
+	// everything fits, nothing to scroll
+	tm, _ = tm.Update(tea.WindowSizeMsg{Width: 200, Height: 100})
+	check(false, false)
+}
+
+func Test_plainMode(t *testing.T) {
+	profile, box, above, below, ellipsis := lipgloss.ColorProfile(), activeBox, moreAboveSymbol, moreBelowSymbol, utils.Ellipsis
+	t.Cleanup(func() {
+		plainMode = false
+		lipgloss.SetColorProfile(profile)
+		activeBox, moreAboveSymbol, moreBelowSymbol, utils.Ellipsis = box, above, below, ellipsis
+	})
+	// as if the settings asked for a Unicode box
+	activeBox = tuiAssets.Fat()
+	usePlainMode()
+	restoreTheme(TuiSettings{Box: "fat"})
+
+	keys, _ := loadKeymap(nil)
+	m := model{
+		selected:       map[int]struct{}{1: {}},
+		sortMode:       SORT_NAME,
+		infos:          make(map[string]clientInfo),
+		details:        make(map[string]clientDetails),
+		detailsLoading: make(map[string]bool),
+		keys:           keys,
+		// too narrow for the details pane next to the list, it goes below
+		width:  60,
+		height: 33,
+	}
+	for i := 0; i < 30; i++ {
+		m.choices = append(m.choices, fmt.Sprintf("Kunde %02d Maschinenbau und Anlagentechnik", i))
+	}
+	view := m.scrollToCursor().View()
+	for _, r := range view {
+		if r > unicode.MaxASCII {
+			t.Fatalf("Found %q in the plain view:\n%s", r, view)
+		}
+	}
+	if lines := strings.Count(view, "\n") + 1; lines > m.height {
+		t.Errorf("View is %d lines high in a terminal of %d:\n%s", lines, m.height, view)
+	}
+	// without colors cursor and selection are told apart by their markers, which the footer explains
+	for _, marker := range []string{"> [ ] Kunde 00", "  [x] Kunde 01", ">: cursor   [x]: selected", "v 23 more below", "...", "collecting details"} {
+		if !strings.Contains(view, marker) {
+			t.Errorf("Missing %q in the plain view:\n%s", marker, view)
+		}
+	}
+	m.help = KEYMAP_MODE_LIST
+	if help := m.View(); !strings.Contains(help, "[x] selected entry") {
+		t.Errorf("Help overlay does not explain the markers:\n%s", help)
+	}
+
+	// the box stays ASCII and the box in the settings untouched
+	m.help = ""
+	next, _ := m.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	if activeBox != tuiAssets.Ascii() || next.(model).message == "" {
+		t.Errorf("Changing the box in plain mode drew %+v with message %q", activeBox, next.(model).message)
+	}
+}
+
+func Test_refreshChoices_keepsDetails(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	useSettings(t, set)
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{MARKER_FILE_NAME: "Kunde A\n", "app.config": "a"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.config": "b"})
+	writeFiles(t, filepath.Join(tgkDir, "Kunde C"), map[string]string{"app.config": "c"})
+	m := model{details: make(map[string]clientDetails), detailsLoading: make(map[string]bool), selected: make(map[int]struct{})}
+	for _, client := range []string{"Kunde B", "Kunde C"} {
+		next, _ := m.update(detailsMsg(collectClientDetails(set, client)))
+		m = next.(model)
+	}
+	if len(m.details) != 2 {
+		t.Fatalf("Got details for %d clients, want 2", len(m.details))
+	}
+
+	// only Kunde C gets a new entry
+	writeFiles(t, filepath.Join(tgkDir, "Kunde C"), map[string]string{"new.config": "new"})
+	m = m.refreshChoices()
+	if _, ok := m.details["Kunde B"]; !ok {
+		t.Errorf("Details of the unchanged Kunde B were dropped")
+	}
+	if _, ok := m.details["Kunde C"]; ok {
+		t.Errorf("Details of the changed Kunde C were kept")
+	}
+
+	// details that were outdated while they were collected are not taken
+	d := collectClientDetails(set, "Kunde B")
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"new.config": "new"})
+	delete(m.details, "Kunde B")
+	next, _ := m.update(detailsMsg(d))
+	if _, ok := next.(model).details["Kunde B"]; ok {
+		t.Errorf("Outdated details of Kunde B were taken")
+	}
+}
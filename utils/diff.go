@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// beyond this many edits the diff is not worth minimising anymore, the rest is reported as replaced
+const maxDiffEdits = 2000
+
+type diffOp struct {
+	kind byte // ' ' equal, '-' only in a, '+' only in b
+	a    int  // index into a before this op
+	b    int  // index into b before this op
+}
+
+// diffLines returns a minimal edit script turning a into b (Myers' algorithm).
+func diffLines(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v[-d-1..d+1] as it was before round d, which is all backtracking needs
+	trace := make([][]int, 0)
+	d := 0
+	for ; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceAll(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+	// walk back from the end, ops are collected in reverse
+	ops := make([]diffOp, 0)
+	x, y := n, m
+	for ; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', x, y})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', x, y})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', x, y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp{' ', x, y})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+func replaceAll(a []string, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := range a {
+		ops = append(ops, diffOp{'-', i, 0})
+	}
+	for j := range b {
+		ops = append(ops, diffOp{'+', len(a), j})
+	}
+	return ops
+}
+
+// UnifiedDiff renders the differences between the lines a and b in unified diff format with the given number of
+// context lines. It returns an empty string if both are equal.
+func UnifiedDiff(a []string, b []string, nameA string, nameB string, context int) string {
+	ops := diffLines(a, b)
+	// find the ranges of ops that make up a hunk: every change plus its context, overlapping ranges merged
+	type span struct{ start, end int }
+	hunks := make([]span, 0)
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i + context + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1].end {
+			hunks[len(hunks)-1].end = end
+		} else {
+			hunks = append(hunks, span{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("--- " + nameA + "\n")
+	sb.WriteString("+++ " + nameB + "\n")
+	for _, h := range hunks {
+		countA, countB := 0, 0
+		body := ""
+		for _, op := range ops[h.start:h.end] {
+			switch op.kind {
+			case ' ':
+				countA++
+				countB++
+				body += " " + a[op.a] + "\n"
+			case '-':
+				countA++
+				body += "-" + a[op.a] + "\n"
+			case '+':
+				countB++
+				body += "+" + b[op.b] + "\n"
+			}
+		}
+		// an empty range is addressed by the line before it
+		startA, startB := ops[h.start].a, ops[h.start].b
+		if countA > 0 {
+			startA++
+		}
+		if countB > 0 {
+			startB++
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB))
+		sb.WriteString(body)
+	}
+	return sb.String()
+}
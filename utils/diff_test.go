@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_UnifiedDiff(t *testing.T) {
+	a := strings.Split("a\nb\nc\nd\ne\nf\ng\nh\ni\nj", "\n")
+	b := strings.Split("a\nb\nc\nD\ne\nf\ng\nh\ni\nj\nk", "\n")
+	got := UnifiedDiff(a, b, "a", "b", 3)
+	// both hunks are 4 lines apart, with 3 lines of context they overlap and have to be merged
+	wantMerged := "--- a\n" +
+		"+++ b\n" +
+		"@@ -1,10 +1,11 @@\n" +
+		" a\n b\n c\n-d\n+D\n e\n f\n g\n h\n i\n j\n+k\n"
+	if got != wantMerged {
+		t.Fatalf("Wanted:\n%s\nGot:\n%s\n", wantMerged, got)
+	}
+	got = UnifiedDiff(a, b, "a", "b", 1)
+	want := "--- a\n" +
+		"+++ b\n" +
+		"@@ -3,3 +3,3 @@\n" +
+		" c\n-d\n+D\n e\n" +
+		"@@ -10,1 +10,2 @@\n" +
+		" j\n+k\n"
+	if got != want {
+		t.Fatalf("Wanted:\n%s\nGot:\n%s\n", want, got)
+	}
+}
+
+func Test_UnifiedDiff_Edges(t *testing.T) {
+	lines := []string{"x", "y"}
+	if got := UnifiedDiff(lines, lines, "a", "b", 3); got != "" {
+		t.Fatalf("Equal input should not produce a diff, got:\n%s\n", got)
+	}
+	want := "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if got := UnifiedDiff([]string{}, lines, "a", "b", 3); got != want {
+		t.Fatalf("Wanted:\n%s\nGot:\n%s\n", want, got)
+	}
+	want = "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n"
+	if got := UnifiedDiff(lines, []string{}, "a", "b", 3); got != want {
+		t.Fatalf("Wanted:\n%s\nGot:\n%s\n", want, got)
+	}
+}
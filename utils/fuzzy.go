@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatch reports whether all runes of pattern occur in s in order, ignoring case. It returns a score, higher for
+// matches that are consecutive or start words, and the rune positions in s that matched. An empty pattern matches
+// everything with score 0.
+func FuzzyMatch(pattern string, s string) (int, []int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	r := []rune(strings.ToLower(s))
+	positions := make([]int, 0, len(p))
+	score := 0
+	j := 0
+	for i := 0; i < len(r) && j < len(p); i++ {
+		if r[i] != p[j] {
+			continue
+		}
+		score++
+		if len(positions) > 0 && positions[len(positions)-1] == i-1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(r[i-1]) && !unicode.IsDigit(r[i-1]) {
+			score += 2
+		}
+		positions = append(positions, i)
+		j++
+	}
+	if j < len(p) {
+		return 0, nil, false
+	}
+	return score, positions, true
+}
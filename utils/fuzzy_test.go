@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_FuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		s         string
+		ok        bool
+		positions []int
+	}{
+		{"", "Customer", true, []int{}},
+		{"cst", "Customer", true, []int{0, 2, 3}},
+		{"CUS", "customer", true, []int{0, 1, 2}},
+		{"mü", "Müller GmbH", true, []int{0, 1}},
+		{"xyz", "Customer", false, nil},
+		{"rc", "Customer", false, nil},
+	}
+	for _, tt := range tests {
+		_, positions, ok := FuzzyMatch(tt.pattern, tt.s)
+		if ok != tt.ok || !slices.Equal(positions, tt.positions) {
+			t.Fatalf("FuzzyMatch(%q, %q)\nWant: %v %v\nGot: %v %v\n", tt.pattern, tt.s, tt.ok, tt.positions, ok, positions)
+		}
+	}
+	// consecutive matches at the start of a word rank higher than scattered ones
+	good, _, _ := FuzzyMatch("cus", "Customer")
+	bad, _, _ := FuzzyMatch("cus", "cloud business")
+	if good <= bad {
+		t.Fatalf("Expected %d > %d for a consecutive match.", good, bad)
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer appending to Path. Before a write would make the file larger than MaxBytes, the file
+// is rotated: Path becomes Path.1, Path.1 becomes Path.2 and so on, keeping at most Backups old files.
+type RotatingFile struct {
+	Path     string
+	MaxBytes int64
+	Backups  int
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+}
+
+func NewRotatingFile(path string, maxBytes int64, backups int) *RotatingFile {
+	return &RotatingFile{Path: path, MaxBytes: maxBytes, Backups: backups}
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.MaxBytes {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *RotatingFile) rotate() error {
+	// Windows cannot rename a file that is still open
+	rf.file.Close()
+	rf.file = nil
+	// the oldest backup falls off the end, every other one moves up by renaming it onto the name just freed
+	os.Remove(fmt.Sprintf("%s.%d", rf.Path, rf.Backups))
+	for i := rf.Backups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.Path, i), fmt.Sprintf("%s.%d", rf.Path, i+1))
+	}
+	if rf.Backups > 0 {
+		if err := os.Rename(rf.Path, rf.Path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.Path); err != nil {
+		return err
+	}
+	return rf.open()
+}
@@ -1,7 +1,12 @@
 package utils
 
 import (
-	"log"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -9,7 +14,11 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/mattn/go-runewidth"
+	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/process"
 )
 
@@ -54,12 +63,12 @@ func Exists(path string) bool {
 	return true
 }
 
-func GetDirsInDir(dir string) []string {
+func GetDirsInDir(dir string) ([]string, error) {
 	// Returns slice of strings containing all directories within given directory
 	// param dir: string -- directory to check
 	entries, err := os.ReadDir(filepath.FromSlash(dir))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	result := make([]string, 0)
 	// translate all vfiles to strings
@@ -68,22 +77,22 @@ func GetDirsInDir(dir string) []string {
 			result = append(result, e.Name())
 		}
 	}
-	return result
+	return result, nil
 }
 
-func GetAllInDir(dir string) []string {
-	// Returns slice of strings containing all directories within given directory
+func GetAllInDir(dir string) ([]string, error) {
+	// Returns slice of strings containing all files and directories within given directory
 	// param dir: string -- directory to check
 	entries, err := os.ReadDir(filepath.FromSlash(dir))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	result := make([]string, 0)
 	// translate all files to strings
 	for _, e := range entries {
 		result = append(result, e.Name())
 	}
-	return result
+	return result, nil
 }
 
 func All[T any](ts []T, pred func(T) bool) bool {
@@ -140,21 +149,73 @@ func KillProcessByName(name string) error {
 			continue
 		}
 		if n == name {
-			return p.Kill()
+			err = p.Kill()
+			if err != nil {
+				slog.Error("could not kill process", "name", name, "pid", p.Pid, "error", err)
+				return err
+			}
+			slog.Info("process killed", "name", name, "pid", p.Pid)
+			return nil
 		}
 	}
+	slog.Debug("process to kill not running", "name", name)
 	// return nil and not an error. Process could not be terminated because it never existed.
 	// fmt.Printf("\nProcess %s could not be terminated because it was not found.\n", name)
 	return nil
 }
 
+// FindProcessesByName returns the PIDs of all running processes called name.
+func FindProcessesByName(name string) ([]int32, error) {
+	pids := make([]int32, 0)
+	processes, err := process.Processes()
+	if err != nil {
+		return pids, err
+	}
+	for _, p := range processes {
+		n, err := p.Name()
+		// same as in KillProcessByName, processes we may not look at are skipped
+		if err != nil {
+			continue
+		}
+		if n == name {
+			pids = append(pids, p.Pid)
+		}
+	}
+	return pids, nil
+}
+
+// FormatBytes renders n with a binary unit, p.e. 1536 as "1.5 KiB".
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// FreeDiskSpace returns the bytes available on the volume that holds path.
+func FreeDiskSpace(path string) (uint64, error) {
+	usage, err := disk.Usage(path)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Free, nil
+}
+
 func StartProgramByName(name string) error {
 	// add string sanitization to name so no arbitrary code can be pushed through
 	cmd := exec.Command("cmd", "/C", "start", name)
 	err := cmd.Run()
 	if err != nil {
+		slog.Error("could not start program", "name", name, "error", err)
 		return err
 	}
+	slog.Info("program started", "name", name)
 	return nil
 }
 
@@ -193,15 +254,135 @@ func RestartProgramByName(name string) error {
 	return err
 }
 
+// PadRight pads toPad with padWith up to width terminal cells; escape sequences, wide and combining characters are
+// measured like DisplayWidth does.
 func PadRight(toPad string, padWith rune, width int) string {
-	if len(toPad) > width {
+	missing := width - DisplayWidth(toPad)
+	if missing <= 0 {
 		return toPad
 	}
-	padding := strings.Repeat(string(padWith), width-len(toPad))
+	padding := strings.Repeat(string(padWith), missing/max(runewidth.RuneWidth(padWith), 1))
 	return toPad + padding
 }
 
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
 func StripANSI(s string) string {
-	ansiRegexp := regexp.MustCompile(`\x1b\[[0-9;]*m`)
 	return ansiRegexp.ReplaceAllString(s, "")
 }
+
+// DisplayWidth returns how many terminal cells s takes up: escape sequences take none, combining characters none and
+// wide characters, p.e. CJK, two.
+func DisplayWidth(s string) int {
+	return runewidth.StringWidth(StripANSI(s))
+}
+
+// Ellipsis ends what Truncate cut off; terminals that cannot show it may use "..." instead.
+var Ellipsis = "\u2026"
+
+// Truncate cuts s down to width terminal cells, ending in Ellipsis if anything was cut. Escape sequences are kept
+// and closed with a reset, so the colors do not bleed into what follows.
+func Truncate(s string, width int) string {
+	if DisplayWidth(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	// room for the text in front of the ellipsis
+	room := max(width-runewidth.StringWidth(Ellipsis), 0)
+	var b strings.Builder
+	used := 0
+	styled := false
+	for len(s) > 0 {
+		if loc := ansiRegexp.FindStringIndex(s); loc != nil && loc[0] == 0 {
+			b.WriteString(s[:loc[1]])
+			s = s[loc[1]:]
+			styled = true
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		w := runewidth.RuneWidth(r)
+		if used+w > room {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+		s = s[size:]
+	}
+	// combining characters belong to the last one written
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if !unicode.In(r, unicode.Mn, unicode.Me) {
+			break
+		}
+		b.WriteRune(r)
+		s = s[size:]
+	}
+	b.WriteString(Ellipsis)
+	if styled {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// HashFile returns the hex encoded SHA-256 digest of the file at path.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CopyFile copies the contents of src to dst, creating or truncating dst with the given permission bits.
+func CopyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// CopyDir copies the directory tree at src to dst, which must not exist yet. Only directories and regular files are
+// copied, files keep their permission bits.
+func CopyDir(src string, dst string) error {
+	if Exists(dst) {
+		return os.ErrExist
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return CopyFile(path, target, info.Mode().Perm())
+	})
+}
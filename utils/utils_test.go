@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
@@ -158,6 +160,109 @@ func Test_Remove(t *testing.T) {
 	}
 }
 
+func Test_HashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatalf("Could not write test file: %s", err)
+	}
+	// sha256 of "abc"
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	got, err := HashFile(path)
+	if err != nil || got != want {
+		t.Fatalf("Hashing failed.\nWant: %s\nGot: %s\nerror: %s\n", want, got, err)
+	}
+	if _, err := HashFile(path + ".missing"); err == nil {
+		t.Fatalf("Expected an error when hashing a file that does not exist.")
+	}
+}
+
+func Test_FormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 40:         "3.0 TiB",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func Test_CopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "dst.txt")
+	if err := os.WriteFile(src, []byte("some content"), 0644); err != nil {
+		t.Fatalf("Could not write test file: %s", err)
+	}
+	// dst already exists and is longer than src, so it has to be truncated
+	if err := os.WriteFile(dst, []byte("some longer old content"), 0644); err != nil {
+		t.Fatalf("Could not write test file: %s", err)
+	}
+	if err := CopyFile(src, dst, 0644); err != nil {
+		t.Fatalf("Could not copy file: %s", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil || string(got) != "some content" {
+		t.Fatalf("Copy has wrong content.\nWant: %s\nGot: %s\nerror: %s\n", "some content", got, err)
+	}
+}
+
+func Test_CopyDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.MkdirAll(filepath.Join(src, "sub", "empty"), 0755); err != nil {
+		t.Fatalf("Could not create test dirs: %s", err)
+	}
+	files := map[string]string{"a.txt": "a", filepath.Join("sub", "b.txt"): "b"}
+	for p, content := range files {
+		if err := os.WriteFile(filepath.Join(src, p), []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write test file: %s", err)
+		}
+	}
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatalf("Could not copy dir: %s", err)
+	}
+	for p, content := range files {
+		got, err := os.ReadFile(filepath.Join(dst, p))
+		if err != nil || string(got) != content {
+			t.Fatalf("Wrong content in %s.\nWant: %q\nGot: %q\nerror: %s\n", p, content, got, err)
+		}
+	}
+	if !Exists(filepath.Join(dst, "sub", "empty")) {
+		t.Fatalf("Empty directories should be copied as well.")
+	}
+	if err := CopyDir(src, dst); err == nil {
+		t.Fatalf("Expected an error when copying onto an existing dir.")
+	}
+}
+
+func Test_RotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	rf := NewRotatingFile(path, 10, 2)
+	defer rf.Close()
+	// every write is 6 bytes, so every write after the first one rotates
+	for _, line := range []string{"one..\n", "two..\n", "three\n", "four.\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("Could not write to rotating file: %s", err)
+		}
+	}
+	want := map[string]string{path: "four.\n", path + ".1": "three\n", path + ".2": "two..\n"}
+	for p, content := range want {
+		got, err := os.ReadFile(p)
+		if err != nil || string(got) != content {
+			t.Fatalf("Wrong content in %s.\nWant: %q\nGot: %q\nerror: %s\n", p, content, got, err)
+		}
+	}
+	if Exists(path + ".3") {
+		t.Fatalf("Only 2 backups should be kept, found a third one.")
+	}
+}
+
 func Test_main(t *testing.T) {
 	// run test functions as subtests so they run sequencially. We do this because both tests test against the Excel-process and might run into raceconditions if run without waiting each other out.
 	t.Run("Restart Test", func(t *testing.T) { TRestartProgramByName(t) })
@@ -260,3 +365,67 @@ func TKillProcessByName(t *testing.T) {
 		}
 	}
 }
+
+func Test_DisplayWidth(t *testing.T) {
+	tests := map[string]int{
+		"":                           0,
+		"Tagetik":                    7,
+		"Müller GmbH":                11,
+		"Mu\u0308ller":               6,
+		"客户端":                        6,
+		"\x1b[38;5;208mÄrzte\x1b[0m": 5,
+	}
+	for s, want := range tests {
+		if got := DisplayWidth(s); got != want {
+			t.Errorf("DisplayWidth(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func Test_PadRight(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"Größe", 7, "Größe  "},
+		{"客户", 6, "客户  "},
+		{"Cafe\u0301", 6, "Cafe\u0301  "},
+		{"\x1b[1mab\x1b[0m", 3, "\x1b[1mab\x1b[0m "},
+		// nothing is cut off
+		{"abcdef", 3, "abcdef"},
+	}
+	for _, tt := range tests {
+		if got := PadRight(tt.s, ' ', tt.width); got != tt.want {
+			t.Errorf("PadRight(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func Test_Truncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 3, "abc"},
+		{"abcdef", 4, "abc…"},
+		{"Müllerstraße", 8, "Müllers…"},
+		// a wide character that does not fit anymore leaves a cell empty
+		{"客户端", 4, "客…"},
+		{"客户端", 5, "客户…"},
+		{"Cafe\u0301s!", 5, "Cafe\u0301…"},
+		{"\x1b[1mabcdef\x1b[0m", 3, "\x1b[1mab…\x1b[0m"},
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.s, tt.width)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+		if DisplayWidth(got) > tt.width {
+			t.Errorf("Truncate(%q, %d) is %d cells wide", tt.s, tt.width, DisplayWidth(got))
+		}
+	}
+}
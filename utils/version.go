@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompareVersions compares version strings like "2023.1.4" or "10.2 SP1" piece by piece, numbers by value and
+// everything else by text, so "10.2" comes after "9.12". It returns -1, 0 or 1 like strings.Compare.
+func CompareVersions(a string, b string) int {
+	pa := versionPieces(a)
+	pb := versionPieces(b)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case errA == nil:
+			// numbers before text, "1.0" < "1.rc"
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(strings.ToLower(pa[i]), strings.ToLower(pb[i])); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(pa) < len(pb):
+		return -1
+	case len(pa) > len(pb):
+		return 1
+	}
+	return 0
+}
+
+// versionPieces splits s into runs of digits and runs of letters, dropping everything else.
+func versionPieces(s string) []string {
+	pieces := make([]string, 0)
+	current := ""
+	digits := false
+	for _, r := range s {
+		isDigit := unicode.IsDigit(r)
+		if !isDigit && !unicode.IsLetter(r) {
+			if current != "" {
+				pieces = append(pieces, current)
+			}
+			current = ""
+			continue
+		}
+		if current != "" && isDigit != digits {
+			pieces = append(pieces, current)
+			current = ""
+		}
+		current += string(r)
+		digits = isDigit
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func Test_CompareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"9.12", "10.2", -1},
+		{"2023.1.4", "2023.1", 1},
+		{"10.2 SP1", "10.2 SP2", -1},
+		{"10.2SP1", "10.2 sp1", 0},
+		{"1.0", "1.rc", -1},
+		{"", "1", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Fatalf("CompareVersions(%q, %q)\nWant: %d\nGot: %d\n", tt.a, tt.b, tt.want, got)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Fatalf("CompareVersions(%q, %q)\nWant: %d\nGot: %d\n", tt.b, tt.a, -tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// changes arriving within this window are reported once, unzipping a client produces hundreds of events
+const watchDebounce = 300 * time.Millisecond
+
+// WatchDirs calls onChange whenever an entry directly inside one of dirs is created, removed, renamed or written.
+// It uses the notifications of the operating system and polls every pollInterval next to them: on network shares
+// notifications may be accepted but never arrive, so the poller has to catch those changes. Where notifications are
+// not available at all only the poller runs. Call stop to end watching.
+func WatchDirs(dirs []string, pollInterval time.Duration, onChange func()) (stop func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		for _, dir := range dirs {
+			if err = watcher.Add(dir); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		slog.Warn("file system notifications not available, polling instead", "dirs", dirs, "error", err)
+		if watcher != nil {
+			watcher.Close()
+		}
+		return PollDirs(dirs, pollInterval, onChange)
+	}
+	done := make(chan struct{})
+	// the poller reports through the same debounce, a change seen by both is reported once
+	polled := make(chan struct{}, 1)
+	stopPolling := PollDirs(dirs, pollInterval, func() {
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		var timer *time.Timer
+		changed := func() {
+			if timer == nil {
+				timer = time.AfterFunc(watchDebounce, onChange)
+			} else {
+				timer.Reset(watchDebounce)
+			}
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				slog.Debug("file system event", "event", event.String())
+				changed()
+			case <-polled:
+				slog.Debug("change found by polling", "dirs", dirs)
+				changed()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("file system watcher error", "error", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			stopPolling()
+			watcher.Close()
+		})
+	}
+}
+
+// PollDirs calls onChange whenever the listing of one of dirs differs from the one pollInterval earlier.
+func PollDirs(dirs []string, pollInterval time.Duration, onChange func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		last := dirsSignature(dirs)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := dirsSignature(dirs)
+				if current != last {
+					last = current
+					onChange()
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// dirsSignature describes the entries of dirs by name, size and modification time.
+func dirsSignature(dirs []string) string {
+	lines := make([]string, 0)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			lines = append(lines, dir+" missing")
+			continue
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s/%s %d %s", dir, e.Name(), info.Size(), info.ModTime()))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
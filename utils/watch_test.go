@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_PollDirs(t *testing.T) {
+	testWatch(t, func(dir string, onChange func()) func() {
+		return PollDirs([]string{dir}, 10*time.Millisecond, onChange)
+	})
+}
+
+func Test_WatchDirs(t *testing.T) {
+	testWatch(t, func(dir string, onChange func()) func() {
+		return WatchDirs([]string{dir}, 10*time.Millisecond, onChange)
+	})
+}
+
+// testWatch checks that creating a folder in a watched dir is reported.
+func testWatch(t *testing.T, watch func(dir string, onChange func()) func()) {
+	dir := t.TempDir()
+	changed := make(chan struct{}, 10)
+	stop := watch(dir, func() { changed <- struct{}{} })
+	defer stop()
+	// give the watcher a moment to take its first look
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Mkdir(filepath.Join(dir, "Customer1"), 0755); err != nil {
+		t.Fatalf("Could not create test dir: %s", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("No change reported after creating a folder.")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fastSwapper/utils"
+)
+
+// Checksum records are plain manifests (see store.go) kept next to the store:
+//
+//	<Tgkdir>/.fastSwapper/checksums/<client>.json
+//
+// They are written by the record command and only ever compared against, nothing is restored from them.
+
+type verifyReport struct {
+	Client   string   `json:"client"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+func (r verifyReport) OK() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Modified) == 0
+}
+
+func (r verifyReport) String() string {
+	if r.OK() {
+		return fmt.Sprintf("%s matches its recorded checksums.", r.Client)
+	}
+	s := fmt.Sprintf("%s differs from its recorded checksums:", r.Client)
+	for _, p := range r.Added {
+		s += "\n  added:    " + p
+	}
+	for _, p := range r.Removed {
+		s += "\n  removed:  " + p
+	}
+	for _, p := range r.Modified {
+		s += "\n  modified: " + p
+	}
+	return s
+}
+
+func checksumsDirPath(tgkDir string) string {
+	return filepath.Join(metaDirPath(tgkDir), "checksums")
+}
+
+func checksumPath(tgkDir string, client string) string {
+	return filepath.Join(checksumsDirPath(tgkDir), client+".json")
+}
+
+// clientFolderPath returns where the folder of client lives; the active client always lives in Tgkfolder.
+func clientFolderPath(set Settings, client string) string {
+	if client == set.ActiveSettings.OldDirectory {
+		return filepath.Join(set.Defaults.Tgkdir, set.Defaults.Tgkfolder)
+	}
+	return filepath.Join(set.Defaults.Tgkdir, client)
+}
+
+// scanClient hashes the folder of client. For the active client rendered config templates are reported with the
+// content they had before rendering.
+func scanClient(set Settings, client string, folderPath string) (clientManifest, error) {
+	m, err := scanFolder(folderPath, client)
+	if err != nil || client != set.ActiveSettings.OldDirectory {
+		return m, err
+	}
+	return withoutTemplates(set.Defaults.Tgkdir, m)
+}
+
+// compareManifests lists the files that were added, removed or modified in actual with respect to recorded.
+func compareManifests(recorded clientManifest, actual clientManifest) verifyReport {
+	r := verifyReport{Client: recorded.Client}
+	recordedHashes := make(map[string]string)
+	for _, f := range recorded.Files {
+		recordedHashes[f.Path] = f.Hash
+	}
+	for _, f := range actual.Files {
+		hash, ok := recordedHashes[f.Path]
+		if !ok {
+			r.Added = append(r.Added, f.Path)
+		} else if hash != f.Hash {
+			r.Modified = append(r.Modified, f.Path)
+		}
+		delete(recordedHashes, f.Path)
+	}
+	for p := range recordedHashes {
+		r.Removed = append(r.Removed, p)
+	}
+	sort.Strings(r.Added)
+	sort.Strings(r.Removed)
+	sort.Strings(r.Modified)
+	return r
+}
+
+// recordChecksums hashes the current folder of client and stores the result as its checksum record.
+func recordChecksums(set Settings, client string) (clientManifest, error) {
+	folderPath := clientFolderPath(set, client)
+	if !utils.Exists(folderPath) {
+		return clientManifest{}, fmt.Errorf("Folder of client %s does not exist.", client)
+	}
+	m, err := scanClient(set, client, folderPath)
+	if err != nil {
+		return m, err
+	}
+	return m, writeManifest(checksumPath(set.Defaults.Tgkdir, client), m)
+}
+
+// verifyClient compares client against its checksum record, or against its store manifest if it was never recorded.
+// A client that only lives in the store has its blobs checked instead of a folder.
+// ok is false if there is nothing to compare against.
+func verifyClient(set Settings, client string) (report verifyReport, ok bool, err error) {
+	tgkDir := set.Defaults.Tgkdir
+	recorded, err := readManifest(checksumPath(tgkDir, client))
+	if os.IsNotExist(err) {
+		recorded, err = readManifest(manifestPath(tgkDir, client))
+	}
+	if os.IsNotExist(err) {
+		return verifyReport{Client: client}, false, nil
+	}
+	if err != nil {
+		return verifyReport{Client: client}, false, err
+	}
+	recorded.Client = client
+	folderPath := clientFolderPath(set, client)
+	if utils.Exists(folderPath) {
+		actual, err := scanClient(set, client, folderPath)
+		if err != nil {
+			return verifyReport{Client: client}, false, err
+		}
+		return compareManifests(recorded, actual), true, nil
+	}
+	return verifyBlobs(tgkDir, recorded), true, nil
+}
+
+// verifyBlobs checks that every blob the manifest points to still exists and still has the content its name promises.
+func verifyBlobs(tgkDir string, m clientManifest) verifyReport {
+	r := verifyReport{Client: m.Client}
+	for _, f := range m.Files {
+		hash, err := utils.HashFile(blobPath(tgkDir, f.Hash))
+		if err != nil {
+			r.Removed = append(r.Removed, f.Path)
+		} else if hash != f.Hash {
+			r.Modified = append(r.Modified, f.Path)
+		}
+	}
+	return r
+}
+
+// verifyBeforeSwap is the optional pre-swap step of swapDirectories. Clients without any record are let through.
+func verifyBeforeSwap(set Settings, newDirName string) error {
+	report, ok, err := verifyClient(set, newDirName)
+	if err != nil {
+		return err
+	}
+	if ok && !report.OK() {
+		return fmt.Errorf("Swap aborted. %s", strings.TrimSpace(report.String()))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_verifyClient(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.xml": "<app/>", "old.xml": "old", "keep.xml": "keep"})
+	if _, ok, err := verifyClient(set, "Kunde B"); ok || err != nil {
+		t.Errorf("Client without a record was verified: ok %t, error %v", ok, err)
+	}
+	if err := verifyBeforeSwap(set, "Kunde B"); err != nil {
+		t.Errorf("Client without a record was not let through: %s", err)
+	}
+	if _, err := recordChecksums(set, "Kunde B"); err != nil {
+		t.Fatal(err)
+	}
+	report, ok, err := verifyClient(set, "Kunde B")
+	if err != nil || !ok || !report.OK() {
+		t.Fatalf("Untouched client does not verify: ok %t, error %v, %s", ok, err, report)
+	}
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.xml": "<changed/>", "new.xml": "new"})
+	if err := os.Remove(filepath.Join(tgkDir, "Kunde B", "old.xml")); err != nil {
+		t.Fatal(err)
+	}
+	report, ok, err = verifyClient(set, "Kunde B")
+	if err != nil || !ok {
+		t.Fatalf("Could not verify: ok %t, error %v", ok, err)
+	}
+	want := verifyReport{Client: "Kunde B", Added: []string{"new.xml"}, Removed: []string{"old.xml"}, Modified: []string{"app.xml"}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Got %+v, want %+v", report, want)
+	}
+	if err := verifyBeforeSwap(set, "Kunde B"); err == nil {
+		t.Errorf("Modified client was let through")
+	}
+	if _, err := recordChecksums(set, "Nobody"); err == nil {
+		t.Errorf("Recording a missing client did not fail")
+	}
+}
+
+func Test_verifyClient_activeClient(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{
+		Defaults:       Default{Tgkdir: tgkDir, Tgkfolder: "Addin"},
+		ActiveSettings: ActiveSettings{OldDirectory: "Kunde A"},
+	}
+	// the active client is found in Tgkfolder, not under its own name
+	writeFiles(t, filepath.Join(tgkDir, "Addin"), map[string]string{"app.xml": "<app/>"})
+	if _, err := recordChecksums(set, "Kunde A"); err != nil {
+		t.Fatal(err)
+	}
+	report, ok, err := verifyClient(set, "Kunde A")
+	if err != nil || !ok || !report.OK() {
+		t.Errorf("Active client does not verify: ok %t, error %v, %s", ok, err, report)
+	}
+}
+
+func Test_verifyClient_storeOnly(t *testing.T) {
+	tgkDir := t.TempDir()
+	set := Settings{Defaults: Default{Tgkdir: tgkDir, Tgkfolder: "Addin", StorageMode: STORAGE_MODE_STORE}}
+	writeFiles(t, filepath.Join(tgkDir, "Kunde B"), map[string]string{"app.xml": "<app/>", "gone.xml": "gone"})
+	if err := storeFolder(tgkDir, "Kunde B", filepath.Join(tgkDir, "Kunde B")); err != nil {
+		t.Fatal(err)
+	}
+	report, ok, err := verifyClient(set, "Kunde B")
+	if err != nil || !ok || !report.OK() {
+		t.Fatalf("Stored client does not verify: ok %t, error %v, %s", ok, err, report)
+	}
+	m, err := readManifest(manifestPath(tgkDir, "Kunde B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range m.Files {
+		blob := blobPath(tgkDir, f.Hash)
+		if f.Path == "gone.xml" {
+			os.Remove(blob)
+			continue
+		}
+		os.Chmod(blob, 0644)
+		if err := os.WriteFile(blob, []byte("bit rot"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, _, _ = verifyClient(set, "Kunde B")
+	want := verifyReport{Client: "Kunde B", Removed: []string{"gone.xml"}, Modified: []string{"app.xml"}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("Got %+v, want %+v", report, want)
+	}
+}